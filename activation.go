@@ -0,0 +1,98 @@
+package weaver
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// listenFDsStart 是 socket activation 传递的第一个文件描述符, 即 systemd 的 SD_LISTEN_FDS_START。
+const listenFDsStart = 3
+
+// activation 是进程启动时通过 socket activation 继承的监听器。
+// 环境变量只在第一次使用时读取一次, 每个监听器只能被一个 weaver.Listener 字段使用。
+var activation struct {
+	once      sync.Once
+	mu        sync.Mutex
+	listeners map[string]net.Listener // 按 LISTEN_FDNAMES 中的名称索引
+	err       error
+}
+
+// activatedListener 返回通过 socket activation 继承的名为 name 的监听器, name 不区分大小写。
+func activatedListener(name string) (net.Listener, bool, error) {
+	activation.once.Do(func() {
+		activation.listeners, activation.err = inheritListeners(os.Getenv, os.Getpid(), listenFDsStart)
+		// 与 sd_listen_fds(1) 一样清除环境变量, 避免子进程误用这些文件描述符
+		for _, key := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+			os.Unsetenv(key)
+		}
+	})
+
+	activation.mu.Lock()
+	defer activation.mu.Unlock()
+	if activation.err != nil {
+		return nil, false, activation.err
+	}
+	for key, lis := range activation.listeners {
+		if strings.EqualFold(key, name) {
+			delete(activation.listeners, key)
+			return lis, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// inheritListeners 按 systemd 的 socket activation 协议读取继承的监听器:
+// LISTEN_FDS 是从 firstFD 开始的文件描述符数量, LISTEN_FDNAMES 是以冒号分隔的名称,
+// 设置了 LISTEN_PID 时它必须等于 pid, 否则这些环境变量是传给其他进程的。
+//
+// 参见 https://www.freedesktop.org/software/systemd/man/latest/sd_listen_fds.html。
+func inheritListeners(getenv func(string) string, pid, firstFD int) (map[string]net.Listener, error) {
+	fds := getenv("LISTEN_FDS")
+	if fds == "" {
+		return nil, nil
+	}
+	if s := getenv("LISTEN_PID"); s != "" && s != strconv.Itoa(pid) {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 0 {
+		return nil, errors.Errorf("LISTEN_FDS %q 不是有效的文件描述符数量", fds)
+	}
+	var names []string
+	if s := getenv("LISTEN_FDNAMES"); s != "" {
+		names = strings.Split(s, ":")
+	}
+	if len(names) != n {
+		return nil, errors.Errorf("LISTEN_FDNAMES 中有 %d 个名称, 但 LISTEN_FDS 是 %d, 每个文件描述符都需要一个与 weaver.Listener 对应的名称", len(names), n)
+	}
+
+	listeners := map[string]net.Listener{}
+	closeAll := func() {
+		for _, lis := range listeners {
+			lis.Close()
+		}
+	}
+	for i, name := range names {
+		if _, ok := listeners[name]; ok {
+			closeAll()
+			return nil, errors.Errorf("LISTEN_FDNAMES 中的名称 %q 重复", name)
+		}
+
+		// net.FileListener 复制文件描述符, 原来的文件描述符随后关闭
+		f := os.NewFile(uintptr(firstFD+i), name)
+		lis, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			closeAll()
+			return nil, errors.Errorf("文件描述符 %d(%s) 不是监听的 socket: %v", firstFD+i, name, err)
+		}
+		listeners[name] = lis
+	}
+	return listeners, nil
+}
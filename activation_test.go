@@ -0,0 +1,148 @@
+package weaver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// dupFD 返回 f 的文件描述符的副本, 由调用方负责关闭。
+func dupFD(t *testing.T, f interface{ Fd() uintptr }) int {
+	t.Helper()
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd
+}
+
+func TestInheritListeners(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	f, err := lis.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+
+	fd := dupFD(t, f)
+	got, err := inheritListeners(env(map[string]string{"LISTEN_PID": "42", "LISTEN_FDS": "1", "LISTEN_FDNAMES": "api"}), 42, fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got["api"] == nil || got["api"].Addr().String() != lis.Addr().String() {
+		t.Fatalf("inheritListeners = %v, want api on %s", got, lis.Addr())
+	}
+	got["api"].Close()
+
+	// LISTEN_PID 是其他进程时忽略
+	if got, err := inheritListeners(env(map[string]string{"LISTEN_PID": "1", "LISTEN_FDS": "1", "LISTEN_FDNAMES": "api"}), 42, -1); err != nil || got != nil {
+		t.Fatalf("inheritListeners for another pid = %v, %v, want nothing", got, err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	for _, test := range []struct {
+		vars map[string]string
+		pipe bool // 传入管道的文件描述符, inheritListeners 会关闭它
+		want string
+	}{
+		{map[string]string{"LISTEN_FDS": "x"}, false, `LISTEN_FDS "x" 不是有效的文件描述符数量`},
+		{map[string]string{"LISTEN_FDS": "2", "LISTEN_FDNAMES": "api"}, false, "LISTEN_FDNAMES 中有 1 个名称, 但 LISTEN_FDS 是 2"},
+		{map[string]string{"LISTEN_FDS": "1", "LISTEN_FDNAMES": "api"}, true, "不是监听的 socket"},
+	} {
+		fd := -1
+		if test.pipe {
+			fd = dupFD(t, r)
+		}
+		if _, err := inheritListeners(env(test.vars), 42, fd); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("inheritListeners(%v) error = %v, want %q", test.vars, err, test.want)
+		}
+	}
+}
+
+// TestSocketActivation 以 socket activation 的方式启动测试进程本身, 检查子进程在继承的 socket 上接受连接。
+func TestSocketActivation(t *testing.T) {
+	if os.Getenv("WEAVER_TEST_ACTIVATION") == "1" {
+		socketActivationChild(t)
+		return
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	f, err := lis.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// 在子进程启动之前连接, 连接在 backlog 中等待子进程 Accept
+	conn, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSocketActivation$")
+	cmd.Env = append(os.Environ(), "WEAVER_TEST_ACTIVATION=1", "LISTEN_FDS=1", "LISTEN_FDNAMES=api")
+	cmd.ExtraFiles = []*os.File{f} // 子进程中的文件描述符 3
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("child failed: %v\n%s", err, out)
+	}
+	for _, want := range []string{"api=" + lis.Addr().String(), "received=hello", "LISTEN_FDS="} {
+		if !strings.Contains(string(out), want+"\n") {
+			t.Errorf("child output does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func socketActivationChild(t *testing.T) {
+	w := newListenerWidget(t, "weaver: {listeners: {api: {address: 127.0.0.1:1}}}", listenerServer{})
+	obj, err := w.getImpl(reflect.TypeOf(listenerServer{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := obj.(*listenerServer)
+	fmt.Println("api=" + s.api.Addr().String())
+	fmt.Println("LISTEN_FDS=" + os.Getenv("LISTEN_FDS"))
+
+	conn, err := s.api.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	buf := make([]byte, len("hello"))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("received=" + string(buf))
+
+	if err := w.shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,258 @@
+package weaver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jun3372/weaver/runtime/metrics"
+)
+
+// redacted 替换敏感配置项的值
+const redacted = "******"
+
+// sensitiveKeys 字段名包含这些关键字的配置项在管理接口中会被脱敏
+var sensitiveKeys = []string{"password", "passwd", "secret", "token", "credential", "apikey", "privatekey"}
+
+// serveAdmin 在 weaver.admin.address 上启动管理服务, 直到 ctx 结束。
+// 未配置地址时不做任何事情。
+//
+//	GET /components     已注册和已实例化的组件, 以及它们的生命周期状态、依赖和监听器
+//	GET /config         各组件生效的配置, 敏感字段已脱敏
+//	GET /healthz        健康检查结果, 不健康时返回 503
+//	GET /readyz         就绪检查结果, 未就绪时返回 503
+//	GET /metrics        Prometheus 文本格式的指标, 包括每个组件方法的调用次数、错误次数和耗时
+//	GET /debug/pprof/   net/http/pprof
+func (w *widget) serveAdmin(ctx context.Context) error {
+	addr := w.option.Admin.Address
+	if addr == "" {
+		return nil
+	}
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: w.adminHandler()}
+	go func() {
+		<-ctx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	go func() {
+		w.logger("weaver").Info("admin server listening", "address", lis.Addr().String())
+		if err := server.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			w.logger("weaver").Error("admin server failed", "err", err)
+		}
+	}()
+
+	return nil
+}
+
+func (w *widget) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /components", func(rw http.ResponseWriter, r *http.Request) {
+		writeJSON(rw, http.StatusOK, w.componentsInfo())
+	})
+	mux.HandleFunc("GET /config", func(rw http.ResponseWriter, r *http.Request) {
+		writeJSON(rw, http.StatusOK, w.effectiveConfig())
+	})
+	mux.HandleFunc("GET /healthz", func(rw http.ResponseWriter, r *http.Request) {
+		report := w.HealthReport()
+		writeJSON(rw, statusCode(report.Healthy), report)
+	})
+	mux.HandleFunc("GET /readyz", func(rw http.ResponseWriter, r *http.Request) {
+		report := w.HealthReport()
+		writeJSON(rw, statusCode(report.Ready), report)
+	})
+	mux.HandleFunc("GET /metrics", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		metrics.WritePrometheus(rw, metrics.Snapshot())
+	})
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+type componentsInfo struct {
+	Registered   []registeredComponent   `json:"registered"`
+	Instantiated []instantiatedComponent `json:"instantiated"`
+}
+
+type registeredComponent struct {
+	Name      string `json:"name"`
+	Interface string `json:"interface"`
+	Impl      string `json:"impl"`
+	ImplName  string `json:"impl_name,omitempty"`
+	Disabled  bool   `json:"disabled,omitempty"`
+	Inactive  bool   `json:"inactive,omitempty"` // 组件有多个实现, 选中的是其他实现
+}
+
+type instantiatedComponent struct {
+	Name      string            `json:"name"`
+	State     string            `json:"state"`
+	Refs      []string          `json:"refs,omitempty"`
+	Listeners map[string]string `json:"listeners,omitempty"` // 监听器名称 -> 实际监听的地址
+}
+
+func (w *widget) componentsInfo() componentsInfo {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var info componentsInfo
+	for _, reg := range w.regs {
+		info.Registered = append(info.Registered, registeredComponent{
+			Name:      reg.Name,
+			Interface: reg.Interface.String(),
+			Impl:      reg.Impl.String(),
+			ImplName:  reg.ImplName,
+			Disabled:  !w.enabled(reg),
+			Inactive:  w.regsByInterface[reg.Interface] != reg,
+		})
+	}
+	sort.Slice(info.Registered, func(i, j int) bool {
+		x, y := info.Registered[i], info.Registered[j]
+		if x.Name != y.Name {
+			return x.Name < y.Name
+		}
+		return x.ImplName < y.ImplName
+	})
+
+	for _, name := range w.order {
+		info.Instantiated = append(info.Instantiated, instantiatedComponent{
+			Name:      name,
+			State:     w.states[name],
+			Refs:      w.deps[name],
+			Listeners: w.listenerAddrs(name),
+		})
+	}
+	return info
+}
+
+// effectiveConfig 返回每个组件 WithConfig 字段当前的值, 按组件名和配置键分组。
+func (w *widget) effectiveConfig() map[string]map[string]any {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	result := make(map[string]map[string]any, len(w.bindings))
+	for name, bindings := range w.bindings {
+		if len(bindings) == 0 {
+			continue
+		}
+
+		configs := make(map[string]any, len(bindings))
+		for _, b := range bindings {
+			configs[b.key] = redact(b.holder.load(), b.secrets)
+		}
+		result[name] = configs
+	}
+	return result
+}
+
+// redact 将配置转换为通用的 JSON 结构, 并隐藏其中的敏感字段、连接串中的密码以及 secrets 中的值,
+// secrets 是配置中由 ${env:...}、${file:...} 等引用解析出的值。
+func redact(cfg any, secrets []string) any {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return redacted
+	}
+
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return redacted
+	}
+	return redactValue("", v, secrets)
+}
+
+func redactValue(key string, v any, secrets []string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, x := range v {
+			v[k] = redactValue(k, x, secrets)
+		}
+		return v
+	case []any:
+		for i, x := range v {
+			v[i] = redactValue(key, x, secrets)
+		}
+		return v
+	case string:
+		if isSensitive(key) {
+			return redacted
+		}
+
+		// 引用解析出的值可能只是字符串的一部分, 例如 postgresql://admin:${env:DB_PASSWORD}@localhost/db
+		for _, secret := range secrets {
+			v = strings.ReplaceAll(v, secret, redacted)
+		}
+
+		if u, err := url.Parse(v); err == nil && u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), redacted)
+				return u.String()
+			}
+		}
+		return redactDSN(v)
+	default:
+		if isSensitive(key) {
+			return redacted
+		}
+		return v
+	}
+}
+
+// redactDSN 隐藏 root:password@tcp(localhost:3306)/db 这类不是 URL 的连接串中的密码。
+// 与 go-sql-driver/mysql 一样, 密码在第一个 : 之后, 到最后一个 / 之前的最后一个 @ 为止。
+func redactDSN(s string) string {
+	at := strings.LastIndex(s, "@")
+	if slash := strings.LastIndex(s, "/"); slash >= 0 {
+		at = strings.LastIndex(s[:slash], "@")
+	}
+	if at < 0 {
+		return s
+	}
+
+	user, password, ok := strings.Cut(s[:at], ":")
+	if !ok || password == "" || strings.ContainsAny(user, " /") {
+		return s
+	}
+	return user + ":" + redacted + s[at:]
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func statusCode(ok bool) int {
+	if ok {
+		return http.StatusOK
+	}
+	return http.StatusServiceUnavailable
+}
+
+func writeJSON(rw http.ResponseWriter, code int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	enc := json.NewEncoder(rw)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,295 @@
+package weaver
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+
+	"github.com/jun3372/weaver/internal/config"
+	"github.com/jun3372/weaver/internal/reflection"
+	"github.com/jun3372/weaver/runtime/codegen"
+)
+
+// App 是一个可以嵌入到其他程序中的 weaver 应用, 与 Run 不同, 它不会解析全局命令行参数,
+// 也不会调用 os.Exit; 只有在使用 WithSignals 时才会监听信号。
+//
+//	app, err := weaver.NewApp[server](weaver.WithConfigFile("weaver.yaml"))
+//	if err != nil {
+//		return err
+//	}
+//	if err := app.Start(ctx); err != nil {
+//		return err
+//	}
+//	defer app.Stop(context.Background())
+//	app.Main().Serve(app.Context())
+type App[T any] struct {
+	*runner
+}
+
+// AppOption 配置 NewApp 创建的应用。
+type AppOption func(*appOptions)
+
+type appOptions struct {
+	conf     *viper.Viper
+	provider ConfigProvider
+	files    []string
+	profile  string
+	regs     []*codegen.Registration
+	logger   *slog.Logger
+	signals  []os.Signal
+	fakes    map[reflect.Type]any
+	standIns map[reflect.Type]any
+
+	interceptors []Interceptor
+}
+
+// WithConfigFile 从 filename 加载配置, 并在文件变化时重新加载。多次使用时按顺序合并所有文件,
+// 后面的文件覆盖前面文件中的同名键; 文件所在目录下 conf.d 目录中的文件也会被合并, 参见 WithProfile。
+func WithConfigFile(filename string) AppOption {
+	return func(o *appOptions) {
+		o.files = append(o.files, filename)
+	}
+}
+
+// WithProfile 在每个配置文件之后合并对应的 profile 配置文件, 例如 profile 为 prod 时
+// weaver.yaml 对应 weaver.prod.yaml; 至少需要存在一个 profile 配置文件。
+func WithProfile(profile string) AppOption {
+	return func(o *appOptions) {
+		o.profile = profile
+	}
+}
+
+// WithViper 使用已经加载好的 conf 作为配置。
+func WithViper(conf *viper.Viper) AppOption {
+	return func(o *appOptions) {
+		o.conf = conf
+	}
+}
+
+// WithRegistry 使用 regs 代替 codegen.Registered() 返回的全局注册组件。
+func WithRegistry(regs []*codegen.Registration) AppOption {
+	return func(o *appOptions) {
+		o.regs = regs
+	}
+}
+
+// WithLogger 使用 logger 代替根据 weaver.logger 配置创建的日志器。
+func WithLogger(logger *slog.Logger) AppOption {
+	return func(o *appOptions) {
+		o.logger = logger
+	}
+}
+
+// WithSignals 在收到任一信号时停止应用, 即取消 App.Context()。
+func WithSignals(signals ...os.Signal) AppOption {
+	return func(o *appOptions) {
+		o.signals = signals
+	}
+}
+
+// WithStandIn 在接口 T 的组件通过 weaver.components.<name>.enabled: false 禁用时,
+// 使用 impl 代替它注入到引用它的组件中。替代实现不参与组件的生命周期管理。
+// 没有替代实现时, 引用已禁用组件的组件会在初始化时返回错误。
+//
+//	weaver.NewApp[server](weaver.WithStandIn[wechat.T](noopWechat{}))
+func WithStandIn[T any](impl T) AppOption {
+	return func(o *appOptions) {
+		if o.standIns == nil {
+			o.standIns = map[reflect.Type]any{}
+		}
+		o.standIns[reflection.Type[T]()] = impl
+	}
+}
+
+// withFakes 使用 fakes 替换对应接口的组件, 供 weavertest 使用。
+func withFakes(fakes map[reflect.Type]any) AppOption {
+	return func(o *appOptions) {
+		o.fakes = fakes
+	}
+}
+
+// NewApp 创建 main 组件为 T 的应用, 组件在调用 Start 时才会被实例化。
+func NewApp[T any, _ PointerToMain[T]](opts ...AppOption) (*App[T], error) {
+	r, err := newRunner(reflection.Type[T](), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &App[T]{runner: r}, nil
+}
+
+// Start 实例化并初始化 main 组件及其依赖, 然后按依赖顺序启动它们。
+func (a *App[T]) Start(ctx context.Context) error {
+	return a.start(ctx)
+}
+
+// Stop 按启动的逆序关闭所有组件并取消 App.Context()。
+func (a *App[T]) Stop(ctx context.Context) error {
+	return a.stop(ctx)
+}
+
+// Main 返回 main 组件, 在 Start 成功之前返回 nil。
+func (a *App[T]) Main() *T {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.main == nil {
+		return nil
+	}
+	return a.main.(*T)
+}
+
+// Run 启动应用, 调用 fn, 并在 fn 返回后停止应用。
+func (a *App[T]) Run(ctx context.Context, fn func(context.Context, *T) error) error {
+	return a.run(ctx, func(ctx context.Context, main any) error {
+		m, ok := main.(*T)
+		if !ok {
+			return errors.New("main type error")
+		}
+		return fn(ctx, m)
+	})
+}
+
+// runner 是 App 与类型无关的实现。
+type runner struct {
+	options  appOptions
+	mainType reflect.Type
+	conf     ConfigProvider
+
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	widget *widget
+	main   any
+}
+
+func newRunner(mainType reflect.Type, opts ...AppOption) (*runner, error) {
+	r := &runner{mainType: mainType}
+	for _, opt := range opts {
+		opt(&r.options)
+	}
+
+	switch {
+	case r.options.provider != nil:
+		r.conf = r.options.provider
+	case r.options.conf != nil:
+		r.conf = newViperProvider(r.options.conf, nil)
+	case len(r.options.files) > 0:
+		files, err := config.NewFiles(r.options.files, r.options.profile)
+		if err != nil {
+			return nil, errors.Errorf("Fatal error config file: %v", err)
+		}
+		conf, err := files.Load()
+		if err != nil {
+			return nil, errors.Errorf("Fatal error config file: %v", err)
+		}
+		r.conf = newViperProvider(conf, files)
+	case r.options.profile != "":
+		return nil, errors.Errorf("profile %q requires a config file", r.options.profile)
+	}
+
+	if r.options.regs == nil {
+		r.options.regs = codegen.Registered()
+	}
+	return r, nil
+}
+
+// Context 返回应用的 context, 它会在 Stop、收到 WithSignals 指定的信号或组件调用 Exec 时被取消。
+func (r *runner) Context() context.Context {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+func (r *runner) start(ctx context.Context) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.widget != nil {
+		return errors.New("app already started")
+	}
+
+	var cancel context.CancelFunc
+	if len(r.options.signals) > 0 {
+		ctx, cancel = signal.NotifyContext(ctx, r.options.signals...)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	w := newWidget(ctx, cancel, r.conf, r.options.regs)
+	w.fakes = r.options.fakes
+	w.standIns = r.options.standIns
+	w.interceptors = r.options.interceptors
+	w.log = r.options.logger
+
+	// 启动失败时关闭已经初始化的组件、打开的监听器和 tracing, 取消 ctx 以停止管理服务
+	defer func() {
+		if err == nil {
+			return
+		}
+		if err := w.shutdown(context.Background()); err != nil {
+			w.logger("weaver").Error("启动失败后关闭应用失败", "err", err)
+		}
+		cancel()
+	}()
+
+	if err = w.startTracing(ctx); err != nil {
+		return err
+	}
+	main, err := w.getImpl(r.mainType)
+	if err != nil {
+		return err
+	}
+
+	// 启动管理服务
+	if err = w.serveAdmin(ctx); err != nil {
+		return err
+	}
+
+	// 启动组件
+	if err = w.start(ctx); err != nil {
+		return err
+	}
+	go w.watchHealth(ctx)
+	w.watchConfig(ctx)
+
+	r.ctx, r.cancel, r.widget, r.main = ctx, cancel, w, main
+	return nil
+}
+
+func (r *runner) stop(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.widget == nil {
+		return nil
+	}
+
+	// 先按逆序关闭组件, 再取消应用的 context, 避免依赖方仍在运行时被依赖的组件已经退出
+	w := r.widget
+	r.widget = nil
+	err := w.shutdown(ctx)
+	r.cancel()
+	return err
+}
+
+func (r *runner) run(ctx context.Context, fn func(context.Context, any) error) error {
+	if err := r.start(ctx); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	main := r.main
+	r.mu.Unlock()
+
+	err := fn(r.Context(), main)
+	if stopErr := r.stop(context.Background()); err == nil {
+		err = stopErr
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package weaver
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/jun3372/weaver/runtime/codegen"
+)
+
+func TestAppStartStop(t *testing.T) {
+	regs := testRegistrations()
+	regs = append(regs, &codegen.Registration{
+		Name:      "test/Main",
+		Interface: reflect.TypeOf((*Main)(nil)).Elem(),
+		Impl:      reflect.TypeOf(testMain{}),
+	})
+
+	// 两个应用可以在同一个进程中独立运行
+	var apps []*App[testMain]
+	for i := 0; i < 2; i++ {
+		app, err := NewApp[testMain](WithRegistry(regs), WithLogger(slog.Default()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := app.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		apps = append(apps, app)
+	}
+
+	events = nil
+	for _, app := range apps {
+		if app.Main() == nil {
+			t.Fatal("Main() = nil after Start")
+		}
+
+		app.Main().Exec()
+		<-app.Context().Done()
+		if err := app.Stop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{
+		"shutdown server", "shutdown cache", "shutdown store",
+		"shutdown server", "shutdown cache", "shutdown store",
+	}
+	if !slices.Equal(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+}
+
+func TestAppStartFailure(t *testing.T) {
+	regs := testRegistrations()
+	regs = append(regs, &codegen.Registration{
+		Name:      "test/Main",
+		Interface: reflect.TypeOf((*Main)(nil)).Elem(),
+		Impl:      reflect.TypeOf(testMain{}),
+	})
+
+	// 管理服务的地址已被占用, 组件初始化之后启动失败
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+	conf := viper.New()
+	conf.SetConfigType("yaml")
+	if err := conf.ReadConfig(strings.NewReader("weaver: {admin: {address: " + taken.Addr().String() + "}}")); err != nil {
+		t.Fatal(err)
+	}
+
+	app, err := NewApp[testMain](WithRegistry(regs), WithViper(conf), WithLogger(slog.Default()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events = nil
+	if err := app.Start(context.Background()); err == nil {
+		t.Fatal("Start() succeeded with the admin address in use")
+	}
+
+	// 已经初始化的组件按逆序关闭
+	want := []string{
+		"init store", "init cache", "init server",
+		"shutdown server", "shutdown cache", "shutdown store",
+	}
+	if !slices.Equal(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	if app.Main() != nil {
+		t.Fatal("Main() != nil after a failed Start")
+	}
+}
+
+type testMain struct {
+	Implements[Main]
+	server Ref[serverComponent]
+}
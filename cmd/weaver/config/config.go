@@ -0,0 +1,122 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jun3372/weaver/internal/config"
+	"github.com/jun3372/weaver/internal/files"
+	"github.com/jun3372/weaver/internal/generate"
+)
+
+var ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the config of a weaver application",
+}
+
+var (
+	schemaTags   string
+	schemaOutput string
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema [packages]",
+	Short: "Print the JSON Schema of the config file",
+	Long:  generate.SchemaUsage,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+
+		schema, err := generate.ConfigSchema(".", args, generate.Options{BuildTags: buildTags(schemaTags)})
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+
+		if schemaOutput == "" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+
+		w := files.NewWriter(schemaOutput)
+		defer w.Cleanup()
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("write %s: %w", schemaOutput, err)
+		}
+		return nil
+	},
+}
+
+var (
+	checkTags    string
+	checkFiles   []string
+	checkProfile string
+)
+
+var checkCmd = &cobra.Command{
+	Use:          "check [packages]",
+	Short:        "Check a config file without running the application",
+	Long:         generate.CheckUsage,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+		if len(checkFiles) == 0 {
+			return fmt.Errorf("no config file specified, use --conf")
+		}
+
+		files, err := config.NewFiles(checkFiles, checkProfile)
+		if err != nil {
+			return err
+		}
+		conf, err := files.Load()
+		if err != nil {
+			return err
+		}
+
+		schema, err := generate.ConfigSchema(".", args, generate.Options{BuildTags: buildTags(checkTags)})
+		if err != nil {
+			return err
+		}
+
+		problems := generate.CheckConfig(schema, config.Settings(conf))
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("found %d problem(s) in the config", len(problems))
+		}
+		return nil
+	},
+}
+
+func init() {
+	checkCmd.Flags().StringVar(&checkTags, "tags", "", "Build tags to use when loading packages")
+	checkCmd.Flags().StringArrayVar(&checkFiles, "conf", nil, "Config file to check, can be repeated to merge multiple files in order")
+	checkCmd.Flags().StringVar(&checkProfile, "profile", "", "Config profile, loads <name>.<profile>.<ext> next to each config file")
+	ConfigCmd.AddCommand(checkCmd)
+
+	schemaCmd.Flags().StringVar(&schemaTags, "tags", "", "Build tags to use when loading packages")
+	schemaCmd.Flags().StringVarP(&schemaOutput, "output", "o", "", "Write the schema to this file instead of stdout")
+	ConfigCmd.AddCommand(schemaCmd)
+}
+
+// buildTags 返回加载包时使用的构建标签, 与 weaver generate 一样忽略 weaver_gen.go。
+func buildTags(tags string) string {
+	if tags == "" {
+		return "ignoreWeaverGen"
+	}
+	return "ignoreWeaverGen," + tags
+}
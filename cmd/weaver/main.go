@@ -1,8 +1,11 @@
 package main
 
 import (
+	"os"
+
 	"github.com/spf13/cobra"
 
+	"github.com/jun3372/weaver/cmd/weaver/config"
 	"github.com/jun3372/weaver/cmd/weaver/generate"
 	"github.com/jun3372/weaver/cmd/weaver/initialization"
 	"github.com/jun3372/weaver/cmd/weaver/version"
@@ -17,8 +20,11 @@ func init() {
 	rootCmd.AddCommand(version.VersionCmd)
 	rootCmd.AddCommand(generate.GenerateCmd)
 	rootCmd.AddCommand(initialization.InitializationCmd)
+	rootCmd.AddCommand(config.ConfigCmd)
 }
 
 func main() {
-	rootCmd.Execute()
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
 }
@@ -4,10 +4,9 @@
 package main
 
 import (
-	"reflect"
-
 	"github.com/jun3372/weaver"
 	"github.com/jun3372/weaver/runtime/codegen"
+	"reflect"
 )
 
 func init() {
@@ -17,3 +16,7 @@ func init() {
 		Impl:      reflect.TypeOf(app{}),
 	})
 }
+
+// Check that app implements the weaver.Main interface.
+var _ weaver.Main = (*app)(nil)
+
@@ -4,6 +4,7 @@
 package wechat
 
 import (
+	"context"
 	"github.com/jun3372/weaver/runtime/codegen"
 	"reflect"
 )
@@ -13,9 +14,36 @@ func init() {
 		Name:      "github.com/jun3372/weaver/examples/demo/wechat/T",
 		Interface: reflect.TypeOf((*T)(nil)).Elem(),
 		Impl:      reflect.TypeOf(impl{}),
+		LocalStubFn: func(invoke codegen.Invoker, caller string) any {
+			return t_local_stub{invoke: invoke, getMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/jun3372/weaver/examples/demo/wechat/T", Method: "Get"})}
+		},
 	})
 }
 
+// Local stub implementations.
+
+type t_local_stub struct {
+	invoke     codegen.Invoker
+	getMetrics *codegen.MethodMetrics
+}
+
+// Check that t_local_stub implements the T interface.
+var _ T = (*t_local_stub)(nil)
+
+func (s t_local_stub) Get() (r0 option) {
+	ctx := context.Background()
+	begin := s.getMetrics.Begin()
+	results, err := s.invoke(ctx, "Get", []any{}, func(ctx context.Context, impl any) ([]any, error) {
+		r0 := impl.(T).Get()
+		return []any{r0}, nil
+	})
+	s.getMetrics.End(begin, err != nil)
+	if len(results) == 1 {
+		r0, _ = results[0].(option)
+	}
+	return
+}
+
 // Check that impl implements the T interface.
 var _ T = (*impl)(nil)
 
@@ -10,8 +10,22 @@ import (
 
 func init() {
 	codegen.Register(codegen.Registration{
-		Name:  "github.com/jun3372/weaver/examples/hello/chat/Chat",
-		Interface: reflect.TypeOf((*Chat)(nil)).Elem(),
-		Impl:  reflect.TypeOf(chat{}),
+		Name:        "github.com/jun3372/weaver/examples/hello/chat/Chat",
+		Interface:   reflect.TypeOf((*Chat)(nil)).Elem(),
+		Impl:        reflect.TypeOf(chat{}),
+		LocalStubFn: func(invoke codegen.Invoker, caller string) any { return chat_local_stub{invoke: invoke} },
 	})
 }
+
+// Local stub implementations.
+
+type chat_local_stub struct {
+	invoke codegen.Invoker
+}
+
+// Check that chat_local_stub implements the Chat interface.
+var _ Chat = (*chat_local_stub)(nil)
+
+// Check that chat implements the Chat interface.
+var _ Chat = (*chat)(nil)
+
@@ -1,12 +1,37 @@
 package main
 
 import (
+	"context"
 	"testing"
+
+	"github.com/jun3372/weaver/examples/hello/user"
+	"github.com/jun3372/weaver/weavertest"
 )
 
-func TestMain(t *testing.T) {
-	// os.Setenv("SERVICE_CONFIG", "/home/zhoujun/code/jun3/golang/github.com/cotton-go/weaver/examples/hello/weaver.yaml")
-	if err := run(); err != nil {
-		t.Fatal(err)
-	}
+type fakeUser struct{}
+
+func (fakeUser) SayHello(_ context.Context, name string) (user.Response, error) {
+	return user.Response{Message: "Hi " + name}, nil
+}
+
+func TestApp(t *testing.T) {
+	weavertest.Run(t, func(ctx context.Context, app *app) error {
+		if got, want := app.Config().AppName, "hello"; got != want {
+			t.Errorf("AppName = %q, want %q", got, want)
+		}
+
+		resp, err := app.Get().SayHello(ctx, "weaver")
+		if err != nil {
+			return err
+		}
+
+		if got, want := resp.Message, "Hi weaver"; got != want {
+			t.Errorf("SayHello = %q, want %q", got, want)
+		}
+		return nil
+	}, weavertest.WithConfig(`
+app:
+  appname: hello
+  version: 1.0.0
+`), weavertest.WithFakes(weavertest.Fake[user.User](fakeUser{})))
 }
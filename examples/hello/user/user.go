@@ -7,7 +7,7 @@ import (
 )
 
 type User interface {
-	SayHello(ctx context.Context, name string) (response, error)
+	SayHello(ctx context.Context, name string) (Response, error)
 }
 
 type user struct {
@@ -20,7 +20,7 @@ type option struct {
 	Source string
 	Type   string
 }
-type response struct {
+type Response struct {
 	Message string
 	Option  option
 }
@@ -41,9 +41,9 @@ func (u *user) Shutdown(ctx context.Context) error {
 	return nil
 }
 
-func (u *user) SayHello(ctx context.Context, name string) (response, error) {
+func (u *user) SayHello(ctx context.Context, name string) (Response, error) {
 	u.Logger(ctx).Info("user SayHello", "name", name)
-	return response{
+	return Response{
 		Message: "Hello " + name,
 		Option:  *u.Config(),
 	}, nil
@@ -4,14 +4,45 @@
 package user
 
 import (
+	"context"
 	"github.com/jun3372/weaver/runtime/codegen"
 	"reflect"
 )
 
 func init() {
 	codegen.Register(codegen.Registration{
-		Name:  "github.com/jun3372/weaver/examples/hello/user/User",
+		Name:      "github.com/jun3372/weaver/examples/hello/user/User",
 		Interface: reflect.TypeOf((*User)(nil)).Elem(),
-		Impl:  reflect.TypeOf(user{}),
+		Impl:      reflect.TypeOf(user{}),
+		LocalStubFn: func(invoke codegen.Invoker, caller string) any {
+			return user_local_stub{invoke: invoke, sayHelloMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/jun3372/weaver/examples/hello/user/User", Method: "SayHello"})}
+		},
 	})
 }
+
+// Local stub implementations.
+
+type user_local_stub struct {
+	invoke          codegen.Invoker
+	sayHelloMetrics *codegen.MethodMetrics
+}
+
+// Check that user_local_stub implements the User interface.
+var _ User = (*user_local_stub)(nil)
+
+func (s user_local_stub) SayHello(ctx context.Context, a0 string) (r0 Response, err error) {
+	begin := s.sayHelloMetrics.Begin()
+	results, err := s.invoke(ctx, "SayHello", []any{a0}, func(ctx context.Context, impl any) ([]any, error) {
+		r0, err := impl.(User).SayHello(ctx, a0)
+		return []any{r0}, err
+	})
+	s.sayHelloMetrics.End(begin, err != nil)
+	if len(results) == 1 {
+		r0, _ = results[0].(Response)
+	}
+	return
+}
+
+// Check that user implements the User interface.
+var _ User = (*user)(nil)
+
@@ -11,8 +11,12 @@ import (
 
 func init() {
 	codegen.Register(codegen.Registration{
-		Name:  "github.com/jun3372/weaver/Main",
+		Name:      "github.com/jun3372/weaver/Main",
 		Interface: reflect.TypeOf((*weaver.Main)(nil)).Elem(),
-		Impl:  reflect.TypeOf(app{}),
+		Impl:      reflect.TypeOf(app{}),
 	})
 }
+
+// Check that app implements the weaver.Main interface.
+var _ weaver.Main = (*app)(nil)
+
@@ -0,0 +1,125 @@
+package weaver
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHealthInterval = 10 * time.Second
+	defaultHealthTimeout  = 3 * time.Second
+)
+
+// HealthReport 是最近一次对所有已实例化组件执行健康检查的结果。
+//
+// 组件可以选择实现以下接口参与检查, 未实现的组件视为健康且就绪:
+//
+//	Health(ctx context.Context) error // 组件是否存活
+//	Ready(ctx context.Context) error  // 组件是否可以对外提供服务
+type HealthReport struct {
+	Healthy    bool              `json:"healthy"`    // 所有组件均健康
+	Ready      bool              `json:"ready"`      // 所有组件均已就绪
+	CheckedAt  time.Time         `json:"checked_at"` // 检查时间, 零值表示尚未检查
+	Components []ComponentHealth `json:"components"` // 各组件的检查结果, 按依赖顺序排列
+}
+
+// ComponentHealth 是单个组件的检查结果。
+type ComponentHealth struct {
+	Name        string `json:"name"`
+	Healthy     bool   `json:"healthy"`
+	Ready       bool   `json:"ready"`
+	HealthError string `json:"health_error,omitempty"`
+	ReadyError  string `json:"ready_error,omitempty"`
+}
+
+// health 周期性地检查组件状态并缓存最近一次的结果。
+type health struct {
+	mu     sync.RWMutex
+	report HealthReport
+}
+
+func (h *health) get() HealthReport {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.report
+}
+
+func (h *health) set(report HealthReport) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.report = report
+}
+
+// HealthReport 返回最近一次缓存的健康检查结果。
+func (w *widget) HealthReport() HealthReport {
+	return w.health.get()
+}
+
+// watchHealth 立即执行一次健康检查, 之后按 weaver.health.interval 周期执行, 直到 ctx 结束。
+func (w *widget) watchHealth(ctx context.Context) {
+	interval := w.option.Health.Interval
+	if interval <= 0 {
+		interval = defaultHealthInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		w.health.set(w.checkHealth(ctx))
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkHealth 按依赖顺序检查所有已实例化的组件。
+func (w *widget) checkHealth(ctx context.Context) HealthReport {
+	timeout := w.option.Health.Timeout
+	if timeout <= 0 {
+		timeout = defaultHealthTimeout
+	}
+
+	w.mu.Lock()
+	order := append([]string(nil), w.order...)
+	components := make([]any, len(order))
+	for i, name := range order {
+		components[i] = w.components[name]
+	}
+	w.mu.Unlock()
+
+	report := HealthReport{
+		Healthy:    true,
+		Ready:      true,
+		CheckedAt:  time.Now(),
+		Components: make([]ComponentHealth, len(order)),
+	}
+	for i, impl := range components {
+		c := ComponentHealth{Name: order[i], Healthy: true, Ready: true}
+		if h, ok := impl.(interface{ Health(_ context.Context) error }); ok {
+			if err := check(ctx, timeout, h.Health); err != nil {
+				c.Healthy, c.HealthError = false, err.Error()
+			}
+		}
+
+		if r, ok := impl.(interface{ Ready(_ context.Context) error }); ok {
+			if err := check(ctx, timeout, r.Ready); err != nil {
+				c.Ready, c.ReadyError = false, err.Error()
+			}
+		}
+
+		report.Healthy = report.Healthy && c.Healthy
+		report.Ready = report.Ready && c.Ready
+		report.Components[i] = c
+	}
+
+	return report
+}
+
+func check(ctx context.Context, timeout time.Duration, fn func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return fn(ctx)
+}
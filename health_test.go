@@ -0,0 +1,57 @@
+package weaver
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jun3372/weaver/runtime/codegen"
+)
+
+type dbComponent interface{}
+type apiComponent interface{}
+
+type db struct {
+	Implements[dbComponent]
+}
+
+func (*db) Health(context.Context) error { return nil }
+func (*db) Ready(context.Context) error  { return errors.New("migrations pending") }
+
+type api struct {
+	Implements[apiComponent]
+	db Ref[dbComponent]
+}
+
+func TestCheckHealth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := newWidget(ctx, cancel, nil, []*codegen.Registration{
+		{Name: "test/db", Interface: reflect.TypeOf((*dbComponent)(nil)).Elem(), Impl: reflect.TypeOf(db{})},
+		{Name: "test/api", Interface: reflect.TypeOf((*apiComponent)(nil)).Elem(), Impl: reflect.TypeOf(api{})},
+	})
+	obj, err := w.getImpl(reflect.TypeOf(api{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report := obj.(*api).HealthReport(); !report.CheckedAt.IsZero() || report.Ready {
+		t.Fatalf("report before the first check = %+v, want an unready zero report", report)
+	}
+
+	w.health.set(w.checkHealth(ctx))
+	report := obj.(*api).HealthReport()
+	if !report.Healthy || report.Ready {
+		t.Fatalf("report = %+v, want healthy and not ready", report)
+	}
+
+	want := []ComponentHealth{
+		{Name: "test/db", Healthy: true, Ready: false, ReadyError: "migrations pending"},
+		{Name: "test/api", Healthy: true, Ready: true},
+	}
+	if !reflect.DeepEqual(report.Components, want) {
+		t.Fatalf("components = %+v, want %+v", report.Components, want)
+	}
+}
@@ -0,0 +1,105 @@
+package weaver
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/jun3372/weaver/runtime/codegen"
+)
+
+// Call 描述一次通过 weaver.Ref 对组件方法的调用。
+type Call struct {
+	Component string // 被调用组件的全名, 例如 github.com/x/app/user/User
+	Caller    string // 发起调用的组件的全名
+	Method    string // 方法名
+
+	// Args 是除了开头的 context.Context 之外的参数, 拦截器不能修改它。
+	Args []any
+
+	// Results 是除了最后的 error 之外的结果, 在 next 返回之后设置。
+	// 拦截器可以替换它, 例如返回缓存的结果, 替换后的元素类型必须与方法的返回值一致。
+	Results []any
+}
+
+// Interceptor 拦截通过 weaver.Ref 对组件方法的调用。拦截器调用 next 继续执行后续的拦截器和方法本身,
+// 可以在调用 next 之前修改 ctx(例如设置超时), 也可以不调用 next 直接返回错误(例如鉴权失败)。
+// 返回的错误会作为方法的 error 返回; 对于没有 error 返回值的方法, 错误会被忽略。
+//
+//	func logging(ctx context.Context, call *weaver.Call, next func(context.Context) error) error {
+//		start := time.Now()
+//		err := next(ctx)
+//		slog.Info("call", "component", call.Component, "method", call.Method, "latency", time.Since(start), "err", err)
+//		return err
+//	}
+type Interceptor func(ctx context.Context, call *Call, next func(ctx context.Context) error) error
+
+// WithInterceptors 为所有通过 weaver.Ref 的方法调用添加拦截器, 先添加的拦截器在外层。
+// 拦截器依赖 weaver generate 生成的本地存根, fake 组件和替代实现的调用不会被拦截。
+func WithInterceptors(interceptors ...Interceptor) AppOption {
+	return func(o *appOptions) {
+		o.interceptors = append(o.interceptors, interceptors...)
+	}
+}
+
+// intercept 返回在 invoke 之外依次执行 interceptors 的 codegen.Invoker。
+func intercept(component, caller string, interceptors []Interceptor, invoke codegen.Invoker) codegen.Invoker {
+	if len(interceptors) == 0 {
+		return invoke
+	}
+
+	return func(ctx context.Context, method string, args []any, call func(context.Context, any) ([]any, error)) ([]any, error) {
+		c := &Call{Component: component, Caller: caller, Method: method, Args: args}
+		next := func(ctx context.Context) (err error) {
+			c.Results, err = invoke(ctx, method, args, call)
+			return err
+		}
+		// 每一层的 next 都是独立的闭包, 拦截器可以多次调用 next, 例如重试
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, inner := interceptors[i], next
+			next = func(ctx context.Context) error {
+				return interceptor(ctx, c, inner)
+			}
+		}
+
+		err := next(ctx)
+		return c.Results, err
+	}
+}
+
+// localStub 返回组件 caller 通过 Ref 引用组件 reg 时得到的值, impl 是 reg 的实例。
+// 本地存根记录方法的调用指标, 并将方法调用依次交给追踪、拦截器和 traffic 配置的流量分配; 配置了 traffic 时会同时实例化另一个实现,
+// 并记录为 caller 的依赖。
+//
+// REQUIRES: w.mu is held.
+func (w *widget) localStub(caller, reg *codegen.Registration, impl any) (any, error) {
+	traffic := w.trafficConfig(reg)
+	if reg.LocalStubFn == nil {
+		if traffic != nil {
+			return nil, errors.Errorf("组件 %q 配置了 traffic, 但没有生成本地存根, 请重新运行 weaver generate", reg.Name)
+		}
+		if len(w.interceptors) > 0 {
+			w.logger("weaver").Warn("组件没有生成本地存根, 拦截器不会生效, 请重新运行 weaver generate", "component", reg.Name)
+		}
+		return impl, nil
+	}
+
+	invoke := func(ctx context.Context, _ string, _ []any, call func(context.Context, any) ([]any, error)) ([]any, error) {
+		return call(ctx, impl)
+	}
+	if traffic != nil {
+		split, ok := w.splits[reg.Name]
+		if !ok {
+			var err error
+			if split, err = w.newTrafficSplit(reg, impl, traffic); err != nil {
+				return nil, err
+			}
+			w.splits[reg.Name] = split
+		}
+		name := instanceName(caller)
+		w.deps[name] = append(w.deps[name], instanceName(split.alternate.reg))
+		invoke = split.invoke
+	}
+	// span 位于拦截器外层, 拦截器中的日志也会带上 trace_id
+	return reg.LocalStubFn(w.traced(reg.Name, caller.Name, intercept(reg.Name, caller.Name, w.interceptors, invoke)), caller.Name), nil
+}
@@ -0,0 +1,107 @@
+package weaver
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestInterceptors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls []string
+	record := func(name string) Interceptor {
+		return func(ctx context.Context, call *Call, next func(context.Context) error) error {
+			calls = append(calls, fmt.Sprintf("%s before %s.%s(%v) from %s", name, call.Component, call.Method, call.Args, call.Caller))
+			err := next(ctx)
+			calls = append(calls, fmt.Sprintf("%s after %v %v", name, call.Results, err))
+			return err
+		}
+	}
+	deny := func(ctx context.Context, call *Call, next func(context.Context) error) error {
+		if call.Args[0] == "mallory" {
+			return errors.New("permission denied")
+		}
+		return next(ctx)
+	}
+	upper := func(ctx context.Context, call *Call, next func(context.Context) error) error {
+		err := next(ctx)
+		if err == nil {
+			call.Results[0] = fmt.Sprintf("%s!", call.Results[0])
+		}
+		return err
+	}
+
+	w := newWidget(ctx, cancel, nil, greeterRegistrations())
+	w.interceptors = []Interceptor{record("outer"), deny, upper, record("inner")}
+	obj, err := w.getImpl(reflect.TypeOf(greeterClient{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	greeter := obj.(*greeterClient).greeter.Get()
+
+	if got, err := greeter.Greet(ctx, "alice"); err != nil || got != "hello alice!" {
+		t.Fatalf("Greet(alice) = %q, %v, want %q", got, err, "hello alice!")
+	}
+	want := []string{
+		"outer before test/greeter/Greeter.Greet([alice]) from test/client",
+		"inner before test/greeter/Greeter.Greet([alice]) from test/client",
+		"inner after [hello alice] <nil>",
+		"outer after [hello alice!] <nil>",
+	}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("calls = %q, want %q", calls, want)
+	}
+
+	calls = nil
+	if got, err := greeter.Greet(ctx, "mallory"); err == nil || got != "" {
+		t.Fatalf("Greet(mallory) = %q, %v, want a permission error", got, err)
+	}
+	want = []string{
+		"outer before test/greeter/Greeter.Greet([mallory]) from test/client",
+		"outer after [] permission denied",
+	}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("calls = %q, want %q", calls, want)
+	}
+}
+
+func TestInterceptorRetry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	attempts := 0
+	flaky := func(ctx context.Context, call *Call, next func(context.Context) error) error {
+		attempts++
+		if attempts == 1 {
+			return errors.New("unavailable")
+		}
+		return next(ctx)
+	}
+	retry := func(ctx context.Context, call *Call, next func(context.Context) error) error {
+		err := next(ctx)
+		if err != nil {
+			err = next(ctx)
+		}
+		return err
+	}
+
+	w := newWidget(ctx, cancel, nil, greeterRegistrations())
+	w.interceptors = []Interceptor{retry, flaky}
+	obj, err := w.getImpl(reflect.TypeOf(greeterClient{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := obj.(*greeterClient).greeter.Get().Greet(ctx, "bob"); err != nil || got != "hello bob" {
+		t.Fatalf("Greet(bob) = %q, %v, want %q", got, err, "hello bob")
+	}
+	if attempts != 2 {
+		t.Fatalf("attempts = %d, want 2", attempts)
+	}
+}
@@ -1,7 +1,34 @@
 package config
 
+import "time"
+
 type Config struct {
-	Logger Logger
+	Logger  Logger
+	Health  Health
+	Admin   Admin
+	Tracing Tracing
+	Env     Env // 使用环境变量覆盖组件配置, 例如 WEAVER_USER_SOURCE 覆盖 user.source
+
+	// Components 按组件配置运行时的行为, 键是 codegen.Registration.Name(例如 github.com/x/app/user/User)
+	// 或者短名称(例如 user.User), 不区分大小写。
+	Components map[string]Component
+
+	// Listeners 配置组件中 weaver.Listener 字段监听的地址, 键是监听器的名称, 不区分大小写。
+	Listeners map[string]Listener
+}
+
+type Component struct {
+	Enabled *bool    // 为 false 时不实例化该组件, 默认启用
+	Impl    string   // 组件有多个实现时选择的实现, 即 weaver:"impl=<name>" 标签中的名称, 为空时使用没有名称的默认实现
+	Traffic *Traffic `json:"traffic,omitempty" yaml:"traffic,omitempty" toml:"traffic,omitempty"`
+}
+
+// Traffic 将通过 weaver.Ref 对组件的调用按权重分配给 Impl 选择的实现和另一个实现, 用于在同一进程内逐步迁移到新的实现。
+// Weight 和 Shadow 支持热更新, Impl 的变化需要重启应用。
+type Traffic struct {
+	Impl   string  // 另一个实现的名称, 没有名称的默认实现可以写成 default
+	Weight float64 `validate:"min=0,max=100"` // 分配给另一个实现的调用百分比, 0 到 100
+	Shadow bool    // 为 true 时每次调用还会在后台调用没有处理该调用的实现, 比较两者的结果并记录不一致
 }
 
 type Logger struct {
@@ -21,6 +48,30 @@ type LoggerFile struct {
 	Compress   bool   // 压缩决定是否应压缩旋转的日志文件。使用gzip。默认情况下不执行压缩。
 }
 
+type Health struct {
+	Interval time.Duration // 健康检查的间隔, 默认 10s
+	Timeout  time.Duration // 单个组件 Health/Ready 检查的超时时间, 默认 3s
+}
+
+type Listener struct {
+	Address string // 监听地址, 如 :8080, 为空时监听 localhost 上的随机端口
+}
+
+type Admin struct {
+	Address string // 管理服务监听地址, 如 127.0.0.1:9090, 为空时不启动
+}
+
+// Tracing 配置组件方法调用的 OpenTelemetry 追踪, Exporter 为 none 时不创建 span。
+type Tracing struct {
+	Exporter string  `default:"none" validate:"oneof=none stdout file otlp"` // none、stdout、file(JSON 文件) 或 otlp(OTLP/HTTP)
+	Sampler  string  `default:"always" validate:"oneof=always never ratio"`
+	Ratio    float64 `default:"1" validate:"min=0,max=1"` // Sampler 为 ratio 时采样的比例
+	Service  string  // 服务名, 默认为可执行文件名
+	File     string  `default:"traces.json"` // Exporter 为 file 时写入的文件, 每行一个 span
+	Endpoint string  // Exporter 为 otlp 时的地址, 例如 localhost:4318 或 https://collector:4318/v1/traces
+	Insecure bool    // Endpoint 不是 URL 时使用 HTTP 而不是 HTTPS
+}
+
 // Tags 返回一个包含支持的配置文件标签的字符串切片。
 // 这个函数没有输入参数。
 // 返回值是一个字符串切片，包含了如"weaver"、"config"等标签，用于标识支持的配置文件类型。
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTag 是声明字段默认值的结构体标签, 例如:
+//
+//	type options struct {
+//		Addr    string        `default:":8080"`
+//		Timeout time.Duration `default:"3s"`
+//		Hosts   []string      `default:"a.example.com,b.example.com"`
+//		Labels  map[string]int `default:"a=1,b=2"`
+//		Pool    struct {
+//			Size int `default:"10"`
+//		}
+//	}
+//
+// 切片和 map 的元素以逗号分隔, map 的键值以等号分隔。嵌套结构体和非 nil 的结构体指针会递归处理。
+const DefaultTag = "default"
+
+// SetDefaults 将 v 中值为零值的字段设置为 default 标签声明的默认值, v 必须是结构体指针。
+func SetDefaults(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("SetDefaults: %T is not a struct pointer", v)
+	}
+	return setDefaults(rv.Elem(), "")
+}
+
+func setDefaults(v reflect.Value, path string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		fieldPath := f.Name
+		if path != "" {
+			fieldPath = path + "." + f.Name
+		}
+
+		field := v.Field(i)
+		if tag, ok := f.Tag.Lookup(DefaultTag); ok && field.IsZero() {
+			if err := parseDefault(field, tag); err != nil {
+				return fmt.Errorf("%s: invalid default %q: %w", fieldPath, tag, err)
+			}
+		}
+
+		switch {
+		case field.Kind() == reflect.Struct:
+			if err := setDefaults(field, fieldPath); err != nil {
+				return err
+			}
+		case field.Kind() == reflect.Pointer && !field.IsNil() && field.Elem().Kind() == reflect.Struct:
+			if err := setDefaults(field.Elem(), fieldPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseDefault 将 s 解析为 v 的类型并赋值给 v。
+func parseDefault(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		items := splitList(s)
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := parseDefault(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		for _, item := range splitList(s) {
+			key, value, ok := strings.Cut(item, "=")
+			if !ok {
+				return fmt.Errorf("map entry %q is not of the form key=value", item)
+			}
+
+			k := reflect.New(v.Type().Key()).Elem()
+			if err := parseDefault(k, key); err != nil {
+				return err
+			}
+			e := reflect.New(v.Type().Elem()).Elem()
+			if err := parseDefault(e, value); err != nil {
+				return err
+			}
+			m.SetMapIndex(k, e)
+		}
+		v.Set(m)
+	case reflect.Pointer:
+		p := reflect.New(v.Type().Elem())
+		if err := parseDefault(p.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(p)
+	default:
+		return fmt.Errorf("default values are not supported for %v", v.Type())
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+
+	items := strings.Split(s, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type server struct {
+	Addr    string         `default:":8080"`
+	Timeout time.Duration  `default:"3s"`
+	Hosts   []string       `default:"a.example.com, b.example.com"`
+	Ports   []int          `default:"80,443"`
+	Weights map[string]int `default:"a=1,b=2"`
+	Debug   bool           `default:"true"`
+	Retry   *int           `default:"3"`
+	Pool    struct {
+		Size int `default:"10"`
+		Idle int
+	}
+}
+
+func TestSetDefaults(t *testing.T) {
+	var got server
+	got.Addr = ":9090" // 非零值不会被默认值覆盖
+	if err := SetDefaults(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	retry := 3
+	want := server{
+		Addr:    ":9090",
+		Timeout: 3 * time.Second,
+		Hosts:   []string{"a.example.com", "b.example.com"},
+		Ports:   []int{80, 443},
+		Weights: map[string]int{"a": 1, "b": 2},
+		Debug:   true,
+		Retry:   &retry,
+	}
+	want.Pool.Size = 10
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("SetDefaults() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSetDefaultsInvalid(t *testing.T) {
+	var v struct {
+		Timeout time.Duration `default:"soon"`
+	}
+	if err := SetDefaults(&v); err == nil {
+		t.Fatal("SetDefaults() = nil, want an error for an invalid duration")
+	}
+}
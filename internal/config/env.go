@@ -0,0 +1,182 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+type Env struct {
+	Disable   bool   // 关闭环境变量覆盖
+	Prefix    string `default:"WEAVER"` // 环境变量前缀, 设置为空字符串表示不使用前缀
+	Separator string `default:"_"`      // 前缀、配置键和字段名之间的分隔符
+	Case      string `default:"upper"`  // 环境变量名的大小写: upper、lower 或 none(与配置文件中的键保持一致)
+}
+
+// EnvOverride 是一个覆盖配置字段的环境变量。
+type EnvOverride struct {
+	Name  string   // 环境变量名, 例如 WEAVER_USER_SOURCE
+	Path  []string // 配置键下的字段路径, 例如 [source]
+	Value string
+}
+
+// Name 返回配置键 key 下字段路径 path 对应的环境变量名。
+func (e Env) Name(key string, path ...string) string {
+	parts := make([]string, 0, len(path)+2)
+	if e.Prefix != "" {
+		parts = append(parts, e.Prefix)
+	}
+	parts = append(parts, strings.Split(key, ".")...)
+	parts = append(parts, path...)
+
+	name := strings.Join(parts, e.Separator)
+	switch strings.ToLower(e.Case) {
+	case "lower":
+		return strings.ToLower(name)
+	case "none":
+		return name
+	default:
+		return strings.ToUpper(name)
+	}
+}
+
+// EnvOverrides 返回覆盖配置键 key 的所有环境变量, t 是配置的类型。
+// 每个导出字段(包括嵌套结构体中的字段)对应一个环境变量, map 类型的字段不支持覆盖。
+func (e Env) EnvOverrides(key string, t reflect.Type, lookup func(string) (string, bool)) []EnvOverride {
+	if e.Disable {
+		return nil
+	}
+
+	var overrides []EnvOverride
+	visiting := map[reflect.Type]bool{} // 用于处理递归类型, 例如 type node struct{ Next *node }
+	var walk func(t reflect.Type, path []string)
+	walk = func(t reflect.Type, path []string) {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if visiting[t] {
+			return
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+
+			fieldPath := append(append([]string(nil), path...), fieldKey(f))
+			ft := f.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+
+			switch {
+			case ft.Kind() == reflect.Struct && ft != durationType:
+				walk(ft, fieldPath)
+			case ft.Kind() == reflect.Map:
+				continue
+			default:
+				name := e.Name(key, fieldPath...)
+				if value, ok := lookup(name); ok {
+					overrides = append(overrides, EnvOverride{Name: name, Path: fieldPath, Value: value})
+				}
+			}
+		}
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		walk(t, nil)
+	}
+	return overrides
+}
+
+// fieldKey 返回字段在配置中的键, 与 viper 一样使用小写的字段名或 mapstructure 标签。
+func fieldKey(f reflect.StructField) string {
+	if name, _, _ := strings.Cut(f.Tag.Get("mapstructure"), ","); name != "" {
+		return name
+	}
+	return strings.ToLower(f.Name)
+}
+
+// Overlay 返回在 raw 的字段路径 path 上设置 value 后的配置, raw 本身不会被修改。
+// 与 viper 一样, 路径不区分大小写: 配置中已有的 Source 等大小写不同的键会被替换为小写的键,
+// 因此 HTTP 等保留键原始大小写的 ConfigProvider 返回的配置同样可以被覆盖。
+func Overlay(raw any, path []string, value any) any {
+	if len(path) == 0 {
+		return value
+	}
+
+	key := strings.ToLower(path[0])
+	m := map[string]any{}
+	var prev any
+	if src, ok := raw.(map[string]any); ok {
+		for k, v := range src {
+			if strings.EqualFold(k, key) {
+				// 大小写不同的键不止一个时, 与 Lookup 一样优先使用完全匹配的键
+				if prev == nil || k == key {
+					prev = v
+				}
+				continue
+			}
+			m[k] = v
+		}
+	}
+
+	m[key] = Overlay(prev, path[1:], value)
+	return m
+}
+
+// Decode 使用与 viper.Unmarshal 相同的规则将 input 解析到 cfg 中。
+// 与 viper 不同的是, 切片和 map 会被整体替换, 而不是与 cfg 中已有的值合并。
+func Decode(input any, cfg any) error {
+	if input == nil {
+		// 配置中没有对应的键时保留 cfg 中的默认值, ZeroFields 会将 nil 解析为零值
+		return nil
+	}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           cfg,
+		WeaklyTypedInput: true,
+		ZeroFields:       true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(input)
+}
+
+// Lookup 返回 settings 中以 . 分隔的配置键 key 对应的值, 与 viper 一样不区分大小写, 不存在时返回 nil。
+func Lookup(settings map[string]any, key string) any {
+	var v any = settings
+	for _, part := range strings.Split(key, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		v, ok = m[part]
+		if ok {
+			continue
+		}
+		for k, e := range m {
+			if strings.EqualFold(k, part) {
+				v, ok = e, true
+				break
+			}
+		}
+		if !ok {
+			return nil
+		}
+	}
+	return v
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEnvOverrides(t *testing.T) {
+	type options struct {
+		Source  string
+		Timeout time.Duration
+		Pool    struct{ MaxSize int }
+		Labels  map[string]string
+		Alias   string `mapstructure:"db_type"`
+	}
+
+	env := map[string]string{
+		"WEAVER_USER_SOURCE":       "postgresql://localhost",
+		"WEAVER_USER_TIMEOUT":      "5s",
+		"WEAVER_USER_POOL_MAXSIZE": "8",
+		"WEAVER_USER_DB_TYPE":      "postgres",
+		"WEAVER_USER_LABELS":       "ignored",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	var e Env
+	if err := SetDefaults(&e); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	var raw any = map[string]any{"source": "file", "type": "postgresql"}
+	for _, o := range e.EnvOverrides("user", reflect.TypeOf(options{}), lookup) {
+		names = append(names, o.Name)
+		raw = Overlay(raw, o.Path, o.Value)
+	}
+
+	wantNames := []string{"WEAVER_USER_SOURCE", "WEAVER_USER_TIMEOUT", "WEAVER_USER_POOL_MAXSIZE", "WEAVER_USER_DB_TYPE"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Fatalf("names = %v, want %v", names, wantNames)
+	}
+
+	wantRaw := map[string]any{
+		"source":  "postgresql://localhost",
+		"type":    "postgresql",
+		"timeout": "5s",
+		"pool":    map[string]any{"maxsize": "8"},
+		"db_type": "postgres",
+	}
+	if !reflect.DeepEqual(raw, wantRaw) {
+		t.Fatalf("raw = %v, want %v", raw, wantRaw)
+	}
+
+	var got options
+	if err := Decode(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Timeout != 5*time.Second || got.Pool.MaxSize != 8 || got.Alias != "postgres" {
+		t.Fatalf("Decode() = %+v", got)
+	}
+}
+
+func TestEnvOverridesRecursiveType(t *testing.T) {
+	type node struct {
+		Name string
+		Next *node
+	}
+
+	env := map[string]string{"WEAVER_LIST_NAME": "head", "WEAVER_LIST_NEXT_NAME": "ignored"}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	var e Env
+	if err := SetDefaults(&e); err != nil {
+		t.Fatal(err)
+	}
+	// 递归的字段不会被展开, 只有外层的字段可以被覆盖
+	overrides := e.EnvOverrides("list", reflect.TypeOf(node{}), lookup)
+	if len(overrides) != 1 || overrides[0].Name != "WEAVER_LIST_NAME" {
+		t.Fatalf("EnvOverrides() = %+v, want only WEAVER_LIST_NAME", overrides)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	for _, test := range []struct {
+		env  Env
+		want string
+	}{
+		{Env{Prefix: "WEAVER", Separator: "_", Case: "upper"}, "WEAVER_USER_SOURCE"},
+		{Env{Prefix: "app", Separator: "__", Case: "lower"}, "app__user__source"},
+		{Env{Separator: ".", Case: "none"}, "user.source"},
+	} {
+		if got := test.env.Name("user", "source"); got != test.want {
+			t.Errorf("%+v.Name() = %q, want %q", test.env, got, test.want)
+		}
+	}
+}
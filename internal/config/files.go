@@ -0,0 +1,251 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// ConfDir 是与基础配置文件同目录的配置片段目录, 其中的文件按文件名顺序合并。
+const ConfDir = "conf.d"
+
+// kubernetesData 是 Kubernetes 挂载 ConfigMap 或 Secret 的目录中指向当前版本的符号链接,
+// 目录中的文件是指向 ..data/<文件名> 的符号链接。更新时只有 ..data 被原子地替换, 文件本身不会产生事件。
+const kubernetesData = "..data"
+
+// Files 是按顺序合并的多层配置文件, 后合并的文件覆盖先合并的文件中的同名键:
+//
+//  1. 基础配置文件, 按指定的顺序, 例如 -conf weaver.yaml -conf weaver.local.yaml;
+//  2. 每个基础配置文件对应的 profile 配置文件, 例如 -profile prod 时 weaver.yaml 对应 weaver.prod.yaml,
+//     其扩展名可以与基础配置文件不同;
+//  3. 基础配置文件所在目录下 conf.d 目录中的文件, 按文件名排序。
+type Files struct {
+	bases   []string
+	profile string
+}
+
+// NewFiles 返回由基础配置文件 bases 和 profile 组成的多层配置, profile 为空表示不使用 profile。
+func NewFiles(bases []string, profile string) (*Files, error) {
+	if len(bases) == 0 {
+		return nil, fmt.Errorf("no config file specified")
+	}
+	f := &Files{bases: bases, profile: profile}
+	if profile == "" {
+		return f, nil
+	}
+
+	for _, base := range bases {
+		if _, ok := f.profileFile(base); ok {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("profile %q: none of %s exists", profile, strings.Join(f.profileFiles(bases[0]), ", "))
+}
+
+// Layers 返回当前按合并顺序排列的配置文件。conf.d 目录在每次调用时重新读取,
+// 因此新增或删除的配置片段会在下一次加载时生效。
+func (f *Files) Layers() ([]string, error) {
+	layers := slices.Clone(f.bases)
+	for _, base := range f.bases {
+		if name, ok := f.profileFile(base); ok {
+			layers = append(layers, name)
+		}
+	}
+
+	for _, dir := range f.confDirs() {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// os.ReadDir 返回的目录项已经按文件名排序
+		for _, e := range entries {
+			if !e.IsDir() && supported(e.Name()) {
+				layers = append(layers, filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	return layers, nil
+}
+
+// Load 读取并合并所有配置文件。
+func (f *Files) Load() (*viper.Viper, error) {
+	layers, err := f.Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	conf := viper.New()
+	for _, name := range layers {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("read config file %s: %w", name, err)
+		}
+
+		// 合并解码后的原始配置, 而不是 AllSettings 返回的配置: AllSettings 会把 wechat.T 这样
+		// 带点的组件名拆成嵌套的键, weaver.components 中的配置因此无法匹配到组件
+		conf.SetConfigType(strings.TrimPrefix(filepath.Ext(name), "."))
+		if err := conf.MergeConfig(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("merge config file %s: %w", name, err)
+		}
+	}
+	return conf, nil
+}
+
+// Settings 返回 conf 中的所有配置。与 AllSettings 不同, 它与运行时一样按顶层配置键调用 conf.Get,
+// 因此 weaver.components 中 wechat.T 这样带点的组件名不会被拆成嵌套的键。
+func Settings(conf *viper.Viper) map[string]any {
+	settings := map[string]any{}
+	for _, key := range conf.AllKeys() {
+		top, _, _ := strings.Cut(key, ".")
+		if _, ok := settings[top]; !ok {
+			settings[top] = conf.Get(top)
+		}
+	}
+	return settings
+}
+
+// Watch 监听所有配置文件以及 conf.d 目录, 任一层发生变化时重新加载配置并调用 fn,
+// 加载失败时调用 onError 并保留之前的配置。Watch 会一直阻塞直到 ctx 被取消。
+func (f *Files) Watch(ctx context.Context, fn func(*viper.Viper), onError func(error)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	// 监听文件所在的目录而不是文件本身, 编辑器保存文件时通常会先删除再创建文件,
+	// Kubernetes 的 ConfigMap 则会替换符号链接
+	watch := func() {
+		for _, dir := range f.watchDirs() {
+			if err := watcher.Add(dir); err != nil && !os.IsNotExist(err) {
+				onError(err)
+			}
+		}
+	}
+	watch()
+
+	const debounce = 100 * time.Millisecond
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			onError(err)
+		case e, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if f.relevant(e.Name) {
+				// 保存一个文件通常会产生多个事件, 合并短时间内的事件只加载一次
+				timer.Reset(debounce)
+			}
+		case <-timer.C:
+			watch()
+			conf, err := f.Load()
+			if err != nil {
+				onError(err)
+				continue
+			}
+			fn(conf)
+		}
+	}
+}
+
+// relevant 判断文件 name 的变化是否会影响配置。
+func (f *Files) relevant(name string) bool {
+	name = filepath.Clean(name)
+	if filepath.Base(name) == kubernetesData && slices.Contains(f.watchDirs(), filepath.Dir(name)) {
+		return true
+	}
+	for _, base := range f.bases {
+		if name == filepath.Clean(base) || slices.Contains(f.profileFiles(base), name) {
+			return true
+		}
+	}
+	for _, dir := range f.confDirs() {
+		if name == dir || (filepath.Dir(name) == dir && supported(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// watchDirs 返回需要监听的目录: 配置文件所在的目录和 conf.d 目录。
+func (f *Files) watchDirs() []string {
+	dirs := f.baseDirs()
+	for _, dir := range f.confDirs() {
+		if !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+func (f *Files) baseDirs() []string {
+	var dirs []string
+	for _, base := range f.bases {
+		if dir := filepath.Dir(filepath.Clean(base)); !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+func (f *Files) confDirs() []string {
+	var dirs []string
+	for _, dir := range f.baseDirs() {
+		dirs = append(dirs, filepath.Join(dir, ConfDir))
+	}
+	return dirs
+}
+
+// profileFile 返回 base 对应的已存在的 profile 配置文件。
+func (f *Files) profileFile(base string) (string, bool) {
+	for _, name := range f.profileFiles(base) {
+		if _, err := os.Stat(name); err == nil {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// profileFiles 返回 base 对应的候选 profile 配置文件, 优先使用与 base 相同的扩展名。
+func (f *Files) profileFiles(base string) []string {
+	if f.profile == "" {
+		return nil
+	}
+
+	base = filepath.Clean(base)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "." + f.profile
+	names := []string{prefix + ext}
+	for _, e := range viper.SupportedExts {
+		if name := prefix + "." + e; !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func supported(name string) bool {
+	return slices.Contains(viper.SupportedExts, strings.TrimPrefix(filepath.Ext(name), "."))
+}
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFilesLoad(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "weaver.yaml")
+	writeFile(t, base, "app: {name: hello, version: 1.0.0}\nuser: {source: prod, type: postgresql}\n")
+	writeFile(t, filepath.Join(dir, "weaver.local.yaml"), "app: {version: 1.0.1}\n")
+	writeFile(t, filepath.Join(dir, "weaver.dev.toml"), "[user]\nsource = \"dev\"\n")
+	writeFile(t, filepath.Join(dir, ConfDir, "20-user.json"), `{"user": {"type": "mysql"}}`)
+	writeFile(t, filepath.Join(dir, ConfDir, "10-app.yaml"), "app: {version: 2.0.0}\n")
+	writeFile(t, filepath.Join(dir, ConfDir, "README.md"), "ignored")
+
+	files, err := NewFiles([]string{base, filepath.Join(dir, "weaver.local.yaml")}, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layers, err := files.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		base,
+		filepath.Join(dir, "weaver.local.yaml"),
+		filepath.Join(dir, "weaver.dev.toml"),
+		filepath.Join(dir, ConfDir, "10-app.yaml"),
+		filepath.Join(dir, ConfDir, "20-user.json"),
+	}
+	if !reflect.DeepEqual(layers, want) {
+		t.Fatalf("Layers() = %v, want %v", layers, want)
+	}
+
+	conf, err := files.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"app.name":    "hello",
+		"app.version": "2.0.0",
+		"user.source": "dev",
+		"user.type":   "mysql",
+	} {
+		if got := conf.GetString(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestFilesLoadDottedComponents(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "weaver.yaml")
+	writeFile(t, base, `
+weaver:
+  components:
+    wechat.T: {enabled: false}
+    github.com/x/app/user/User: {impl: redis, traffic: {impl: default, weight: 10}}
+`)
+	writeFile(t, filepath.Join(dir, ConfDir, "10-user.toml"), `
+[weaver.components."github.com/x/app/user/User".traffic]
+weight = 50
+`)
+
+	files, err := NewFiles([]string{base}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := files.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := new(Config)
+	if err := conf.UnmarshalKey("weaver", c); err != nil {
+		t.Fatal(err)
+	}
+	if wechat := c.Components["wechat.t"]; wechat.Enabled == nil || *wechat.Enabled {
+		t.Errorf("components[wechat.t] = %+v, want enabled: false", wechat)
+	}
+	user := c.Components["github.com/x/app/user/user"]
+	if user.Impl != "redis" || user.Traffic == nil || user.Traffic.Impl != "default" || user.Traffic.Weight != 50 {
+		t.Errorf("components[github.com/x/app/user/user] = %+v, traffic %+v, want impl redis and traffic default/50", user, user.Traffic)
+	}
+}
+
+func TestFilesMissingProfile(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "weaver.yaml")
+	writeFile(t, base, "app: {name: hello}\n")
+	if _, err := NewFiles([]string{base}, "prod"); err == nil {
+		t.Fatal("NewFiles() with a missing profile succeeded, want error")
+	}
+}
+
+func TestFilesWatch(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "weaver.yaml")
+	writeFile(t, base, "app: {version: 1}\n")
+
+	files, err := NewFiles([]string{base}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	loaded := make(chan *viper.Viper, 10)
+	go files.Watch(ctx, func(conf *viper.Viper) { loaded <- conf }, func(err error) { t.Log(err) })
+
+	next := func() *viper.Viper {
+		t.Helper()
+		select {
+		case conf := <-loaded:
+			return conf
+		case <-time.After(5 * time.Second):
+			t.Fatal("config was not reloaded")
+			return nil
+		}
+	}
+
+	// 等待监听开始: 反复修改基础配置文件直到收到第一次重新加载
+	deadline := time.After(5 * time.Second)
+	for ready := false; !ready; {
+		writeFile(t, base, "app: {version: 2}\n")
+		select {
+		case conf := <-loaded:
+			if got := conf.GetInt("app.version"); got != 2 {
+				t.Fatalf("app.version = %d, want 2", got)
+			}
+			ready = true
+		case <-time.After(200 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("config was not reloaded")
+		}
+	}
+
+	// 在新建的 conf.d 目录中添加配置片段
+	writeFile(t, filepath.Join(dir, ConfDir, "override.yaml"), "app: {version: 3}\n")
+	for {
+		if conf := next(); conf.GetInt("app.version") == 3 {
+			break
+		}
+	}
+}
+
+// TestFilesWatchConfigMap 模拟 Kubernetes 更新挂载的 ConfigMap: 配置文件是指向 ..data/weaver.yaml 的符号链接,
+// 更新时创建新版本的目录, 再将指向它的 ..data_tmp 重命名为 ..data。
+func TestFilesWatchConfigMap(t *testing.T) {
+	dir := t.TempDir()
+	version := 0
+	swap := func() {
+		t.Helper()
+		version++
+		data := fmt.Sprintf("..v%d", version)
+		writeFile(t, filepath.Join(dir, data, "weaver.yaml"), fmt.Sprintf("app: {version: %d}\n", version))
+		tmp := filepath.Join(dir, "..data_tmp")
+		if err := os.Symlink(data, tmp); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, filepath.Join(dir, kubernetesData)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	swap()
+	base := filepath.Join(dir, "weaver.yaml")
+	if err := os.Symlink(filepath.Join(kubernetesData, "weaver.yaml"), base); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := NewFiles([]string{base}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	loaded := make(chan *viper.Viper, 10)
+	go files.Watch(ctx, func(conf *viper.Viper) { loaded <- conf }, func(err error) { t.Log(err) })
+
+	// 监听开始之前的替换不会产生事件, 因此反复替换直到收到重新加载的配置
+	deadline := time.After(5 * time.Second)
+	for {
+		swap()
+		select {
+		case conf := <-loaded:
+			if got := conf.GetInt("app.version"); got < 2 || got > version {
+				t.Fatalf("app.version = %d, want a version between 2 and %d", got, version)
+			}
+			return
+		case <-time.After(200 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("config was not reloaded after swapping ..data")
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrSecretNotFound 表示引用的值不存在, 例如环境变量未设置或文件不存在。
+// 解析器返回的错误包装了它时, 会使用引用中 :- 之后的默认值。
+var ErrSecretNotFound = errors.New("secret not found")
+
+// Resolver 返回引用 ref 对应的值, ref 是 ${scheme:ref} 中冒号之后的部分。
+type Resolver func(ctx context.Context, ref string) (string, error)
+
+var (
+	resolversMu sync.RWMutex
+	resolvers   = map[string]Resolver{
+		"env":  resolveEnv,
+		"file": resolveFile,
+	}
+)
+
+// secretRef 匹配 ${scheme:ref}, 以 $$ 开头的 $${...} 表示不解析, 结果为 ${...}。
+var secretRef = regexp.MustCompile(`\$?\$\{([a-zA-Z][a-zA-Z0-9_-]*):([^}]*)\}`)
+
+// RegisterResolver 注册 scheme 的解析器, 之后配置值中的 ${scheme:ref} 会被替换为 resolver 返回的值。
+// scheme 已经注册时 RegisterResolver 会 panic。
+func RegisterResolver(scheme string, resolver Resolver) {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+	if scheme == "" || resolver == nil {
+		panic("config: RegisterResolver with an empty scheme or nil resolver")
+	}
+	if _, ok := resolvers[scheme]; ok {
+		panic(fmt.Sprintf("config: RegisterResolver called twice for scheme %q", scheme))
+	}
+	resolvers[scheme] = resolver
+}
+
+// ResolveSecrets 返回将 raw 中所有字符串里的 ${scheme:ref} 替换为解析结果后的配置, raw 本身不会被修改。
+// ref 可以以 :- 指定默认值, 例如 ${env:DB_PASSWORD:-admin}, 在解析器返回 ErrSecretNotFound 时使用。
+// secrets 是替换进配置的所有值(包括使用的默认值), 用于在展示配置时隐藏它们。
+func ResolveSecrets(ctx context.Context, raw any) (resolved any, secrets []string, err error) {
+	r := &secretsResolver{ctx: ctx}
+	if resolved, err = r.resolve(raw); err != nil {
+		return nil, nil, err
+	}
+	return resolved, r.secrets, nil
+}
+
+// secretsResolver 解析一份配置中的引用, 并记录替换进配置的值。
+type secretsResolver struct {
+	ctx     context.Context
+	secrets []string
+}
+
+func (r *secretsResolver) resolve(raw any) (any, error) {
+	switch v := raw.(type) {
+	case string:
+		return r.resolveString(v)
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			x, err := r.resolve(e)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = x
+		}
+		return m, nil
+	case []any:
+		s := make([]any, len(v))
+		for i, e := range v {
+			x, err := r.resolve(e)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = x
+		}
+		return s, nil
+	case []string:
+		s := make([]string, len(v))
+		for i, e := range v {
+			x, err := r.resolveString(e)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = x
+		}
+		return s, nil
+	default:
+		return raw, nil
+	}
+}
+
+func (r *secretsResolver) resolveString(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var errs []error
+	resolved := secretRef.ReplaceAllStringFunc(s, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+
+		m := secretRef.FindStringSubmatch(match)
+		scheme, ref := m[1], m[2]
+		ref, def, hasDefault := strings.Cut(ref, ":-")
+
+		resolversMu.RLock()
+		resolver, ok := resolvers[scheme]
+		resolversMu.RUnlock()
+		if !ok {
+			errs = append(errs, fmt.Errorf("${%s:%s}: unknown secret resolver %q", scheme, ref, scheme))
+			return match
+		}
+
+		value, err := resolver(r.ctx, ref)
+		if err != nil {
+			if hasDefault && errors.Is(err, ErrSecretNotFound) {
+				r.record(def)
+				return def
+			}
+			errs = append(errs, fmt.Errorf("${%s:%s}: %w", scheme, ref, err))
+			return match
+		}
+		r.record(value)
+		return value
+	})
+	if len(errs) > 0 {
+		return "", errors.Join(errs...)
+	}
+	return resolved, nil
+}
+
+func (r *secretsResolver) record(value string) {
+	if value != "" && !slices.Contains(r.secrets, value) {
+		r.secrets = append(r.secrets, value)
+	}
+}
+
+func resolveEnv(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set: %w", name, ErrSecretNotFound)
+	}
+	return value, nil
+}
+
+// resolveFile 返回文件内容, 并去掉末尾的换行符, 适用于 Docker 和 Kubernetes 挂载的 secret 文件。
+func resolveFile(_ context.Context, name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("%w: %w", err, ErrSecretNotFound)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// cmdOutputs 缓存 ResolveCmd 执行成功的命令的输出, 键是命令。
+var cmdOutputs struct {
+	sync.Mutex
+	m map[string]string
+}
+
+// ResolveCmd 使用 sh -c 执行命令并返回标准输出, 去掉末尾的换行符。每个命令只成功执行一次,
+// 之后返回缓存的输出, 配置热更新和组件注入配置时不会重复执行。
+//
+// ResolveCmd 默认不注册: 环境变量覆盖和远程配置中的值同样会被解析, 注册后任何能修改配置的人
+// 都可以在进程中执行命令。只有所有配置来源都可信时, 才应通过 RegisterResolver("cmd", ResolveCmd) 启用。
+func ResolveCmd(ctx context.Context, command string) (string, error) {
+	cmdOutputs.Lock()
+	defer cmdOutputs.Unlock()
+	if out, ok := cmdOutputs.m[command]; ok {
+		return out, nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+
+	out := strings.TrimRight(stdout.String(), "\r\n")
+	if cmdOutputs.m == nil {
+		cmdOutputs.m = map[string]string{}
+	}
+	cmdOutputs.m[command] = out
+	return out, nil
+}
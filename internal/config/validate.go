@@ -0,0 +1,176 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidateTag 是声明校验规则的结构体标签, 多个规则以逗号分隔, 例如:
+//
+//	type options struct {
+//		Source string        `validate:"required"`
+//		Type   string        `validate:"oneof=postgres mysql"`
+//		Pool   int           `validate:"min=1,max=100"`
+//		Idle   time.Duration `validate:"min=1s"`
+//	}
+//
+// 支持的规则:
+//
+//	required   值不能是零值
+//	min=N      数字不小于 N; 字符串、切片和 map 的长度不小于 N; time.Duration 可以写成 1s 等形式
+//	max=N      同 min, 不大于 N
+//	oneof=a b  值必须是以空格分隔的候选值之一
+const ValidateTag = "validate"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// FieldError 描述一个未通过校验的字段。
+type FieldError struct {
+	Path string // 字段路径, 例如 Database.Hosts[0].Port
+	Rule string // 未通过的规则, 例如 min=1; Validate 方法返回的错误为 Validate
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// Validate 按 validate 标签递归地校验 v, 并调用实现了 Validate() error 方法的类型的该方法。
+// 返回的错误包含所有未通过校验的字段, 每个字段对应一个 *FieldError。
+func Validate(v any) error {
+	var errs []error
+	validateValue(reflect.ValueOf(v), "", &errs)
+	return errors.Join(errs...)
+}
+
+func validateValue(v reflect.Value, path string, errs *[]error) {
+	if !v.IsValid() {
+		return
+	}
+
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+
+			fieldPath := f.Name
+			if path != "" {
+				fieldPath = path + "." + f.Name
+			}
+
+			field := v.Field(i)
+			if tag, ok := f.Tag.Lookup(ValidateTag); ok {
+				for _, rule := range strings.Split(tag, ",") {
+					if rule = strings.TrimSpace(rule); rule == "" {
+						continue
+					}
+					if err := checkRule(field, rule); err != nil {
+						*errs = append(*errs, &FieldError{Path: fieldPath, Rule: rule, Err: err})
+					}
+				}
+			}
+
+			validateValue(field, fieldPath, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			validateValue(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()), errs)
+		}
+	}
+
+	if v.CanAddr() {
+		v = v.Addr()
+	}
+	if i, ok := v.Interface().(interface{ Validate() error }); ok {
+		if err := i.Validate(); err != nil {
+			*errs = append(*errs, &FieldError{Path: path, Rule: "Validate", Err: err})
+		}
+	}
+}
+
+func checkRule(v reflect.Value, rule string) error {
+	name, arg, _ := strings.Cut(rule, "=")
+	switch name {
+	case "required":
+		if v.IsZero() {
+			return errors.New("is required")
+		}
+	case "min", "max":
+		n, err := size(v)
+		if err != nil {
+			return err
+		}
+		limit, err := parseLimit(v.Type(), arg)
+		if err != nil {
+			return fmt.Errorf("invalid rule %q: %w", rule, err)
+		}
+		if name == "min" && n < limit {
+			return fmt.Errorf("must be at least %s", arg)
+		}
+		if name == "max" && n > limit {
+			return fmt.Errorf("must be at most %s", arg)
+		}
+	case "oneof":
+		value := fmt.Sprint(v.Interface())
+		options := strings.Fields(arg)
+		for _, option := range options {
+			if value == option {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of [%s], got %q", strings.Join(options, " "), value)
+	default:
+		return fmt.Errorf("unknown validation rule %q", rule)
+	}
+	return nil
+}
+
+// size 返回 min/max 规则比较的数值: 数字的值, 或字符串、切片和 map 的长度。
+func size(v reflect.Value) (float64, error) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), nil
+	default:
+		return 0, fmt.Errorf("min/max are not supported for %v", v.Type())
+	}
+}
+
+func parseLimit(t reflect.Type, arg string) (float64, error) {
+	if t == durationType {
+		if d, err := time.ParseDuration(arg); err == nil {
+			return float64(d), nil
+		}
+	}
+	return strconv.ParseFloat(arg, 64)
+}
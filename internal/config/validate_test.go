@@ -0,0 +1,78 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type pool struct {
+	Size int           `validate:"min=1,max=10"`
+	Idle time.Duration `validate:"min=1s"`
+}
+
+type database struct {
+	Source string `validate:"required"`
+	Type   string `validate:"oneof=postgres mysql"`
+	Pools  []pool
+}
+
+func (d database) Validate() error {
+	if d.Type == "mysql" && strings.HasPrefix(d.Source, "postgres") {
+		return errors.New("source does not match type")
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		config database
+		want   []string // 期望出现在错误中的字段路径和原因, 为空表示校验通过
+	}{
+		{
+			name:   "valid",
+			config: database{Source: "postgres://localhost", Type: "postgres", Pools: []pool{{Size: 1, Idle: time.Second}}},
+		},
+		{
+			name:   "required",
+			config: database{Type: "postgres"},
+			want:   []string{"Source: is required"},
+		},
+		{
+			name:   "oneof",
+			config: database{Source: "x", Type: "sqlite"},
+			want:   []string{`Type: must be one of [postgres mysql], got "sqlite"`},
+		},
+		{
+			name:   "nested",
+			config: database{Source: "x", Type: "mysql", Pools: []pool{{Size: 1, Idle: time.Second}, {Size: 11}}},
+			want:   []string{"Pools[1].Size: must be at most 10", "Pools[1].Idle: must be at least 1s"},
+		},
+		{
+			name:   "Validate method",
+			config: database{Source: "postgres://localhost", Type: "mysql"},
+			want:   []string{"source does not match type"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := Validate(&test.config)
+			if len(test.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("Validate() = nil, want %v", test.want)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package generate
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jun3372/weaver/runtime/codegen"
+)
+
+const CheckUsage = `Check a Service Weaver config file without running the application.
+
+Usage:
+  weaver config check [--conf file]... [--profile name] [--tags taglist] [packages]
+
+Description:
+  "weaver config check" loads the config files in the same way as the -conf and
+  -profile flags of a weaver application, and checks them against the
+  weaver.WithConfig[T] fields found in the provided packages. It reports:
+
+    - config keys used by a weaver.WithConfig field that are missing from the
+      config files, and required fields that have neither a value nor a default;
+    - keys, at any level, that no struct field reads;
+    - values that cannot be decoded into the type of the target field, or that
+      violate its validate tag;
+    - invalid values in the "weaver" section, such as weaver.logger.level.
+
+  The command exits with a non-zero status if any problem is found.
+
+Examples:
+  # Check weaver.yaml against the package in the current directory.
+  weaver config check --conf weaver.yaml
+
+  # Check the prod profile of etc/weaver.yaml against all packages.
+  weaver config check --conf etc/weaver.yaml --profile prod ./...`
+
+// Problem 是配置文件中的一个问题。
+type Problem struct {
+	Key     string // 出现问题的配置键, 例如 wechat.appid
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Key, p.Message)
+}
+
+// CheckConfig 按 ConfigSchema 返回的 schema 检查配置 settings, 返回按配置键排序的问题。
+// settings 中的键必须是小写的, 与 viper.AllSettings 返回的一样。
+func CheckConfig(schema *Schema, settings map[string]any) []Problem {
+	c := &checker{}
+	c.checkComponents(schema, settings, "")
+	c.check(schema, settings, "")
+	c.checkLogger(settings)
+
+	sort.SliceStable(c.problems, func(i, j int) bool {
+		return c.problems[i].Key < c.problems[j].Key
+	})
+	return c.problems
+}
+
+type checker struct {
+	problems []Problem
+}
+
+func (c *checker) report(key, format string, args ...any) {
+	c.problems = append(c.problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+// checkComponents 报告组件使用但配置文件中不存在的配置键。
+func (c *checker) checkComponents(s *Schema, value map[string]any, path string) {
+	for name, p := range s.Properties {
+		key := join(path, name)
+		v, ok := value[name]
+		if len(p.Component) > 0 && (!ok || v == nil) {
+			names := make([]string, len(p.Component))
+			for i, comp := range p.Component {
+				names[i] = codegen.ShortName(comp)
+			}
+			c.report(key, "缺少配置键, 组件 %s 使用了它", strings.Join(names, ", "))
+			continue
+		}
+
+		// 嵌套的配置键, 例如 db.primary, 父级不存在时其中的配置键同样缺少
+		if len(p.Component) == 0 && name != systemConfigKey {
+			m, _ := v.(map[string]any)
+			c.checkComponents(p, m, key)
+		}
+	}
+}
+
+// check 检查 value 能否解析为 s 描述的类型, 规则与 config.Decode 的弱类型解析一致。
+func (c *checker) check(s *Schema, value any, path string) {
+	if value == nil {
+		return
+	}
+
+	kinds := schemaTypes(s)
+	if len(kinds) == 0 {
+		// 任意类型
+		return
+	}
+
+	var errs []string
+	for _, t := range kinds {
+		err := c.checkType(s, t, value, path)
+		if err == "" {
+			return
+		}
+		errs = append(errs, err)
+	}
+	c.report(path, "%s", errs[0])
+}
+
+// checkType 检查 value 能否解析为类型 t, 不能时返回原因。对象和数组的元素中的问题会直接报告。
+func (c *checker) checkType(s *Schema, t string, value any, path string) string {
+	rv := reflect.ValueOf(value)
+	switch t {
+	case "object":
+		m, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Sprintf("需要一个对象, 但值是 %s", describe(value))
+		}
+
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			key := join(path, k)
+			if p, ok := s.Properties[k]; ok {
+				c.check(p, m[k], key)
+			} else if s.AdditionalProperties != nil {
+				c.check(s.AdditionalProperties, m[k], key)
+			} else if s.Properties != nil {
+				if path == "" {
+					c.report(key, "未使用的顶层配置键, 没有组件读取它")
+				} else {
+					c.report(key, "未使用的配置键, 没有字段读取它")
+				}
+			}
+		}
+
+		for _, r := range s.Required {
+			if v, ok := m[r]; (!ok || v == nil) && s.Properties[r].Default == nil {
+				c.report(join(path, r), "缺少必填的配置")
+			}
+		}
+		return ""
+
+	case "array":
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < rv.Len(); i++ {
+				c.check(s.Items, rv.Index(i).Interface(), fmt.Sprintf("%s[%d]", path, i))
+			}
+		case reflect.Map:
+			return fmt.Sprintf("需要一个数组, 但值是 %s", describe(value))
+		}
+		// 字符串会按逗号分隔, 其他标量会被当作只有一个元素的数组
+		return ""
+
+	case "integer", "number":
+		var n float64
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = float64(rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n = float64(rv.Uint())
+		case reflect.Float32, reflect.Float64:
+			n = rv.Float()
+			if t == "integer" && n != float64(int64(n)) {
+				return fmt.Sprintf("需要一个整数, 但值是 %v", value)
+			}
+		case reflect.Bool:
+			return ""
+		case reflect.String:
+			var err error
+			if t == "integer" {
+				var i int64
+				i, err = strconv.ParseInt(rv.String(), 0, 64)
+				n = float64(i)
+			} else {
+				n, err = strconv.ParseFloat(rv.String(), 64)
+			}
+			if err != nil {
+				return fmt.Sprintf("需要一个数字, 但值是 %q", value)
+			}
+		default:
+			return fmt.Sprintf("需要一个数字, 但值是 %s", describe(value))
+		}
+
+		if s.Minimum != nil && n < *s.Minimum {
+			return fmt.Sprintf("不能小于 %v, 但值是 %v", *s.Minimum, n)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			return fmt.Sprintf("不能大于 %v, 但值是 %v", *s.Maximum, n)
+		}
+		return c.checkEnum(s, value)
+
+	case "boolean":
+		switch rv.Kind() {
+		case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+			return ""
+		case reflect.String:
+			if _, err := strconv.ParseBool(rv.String()); err != nil && rv.String() != "" {
+				return fmt.Sprintf("需要一个布尔值, 但值是 %q", value)
+			}
+			return ""
+		default:
+			return fmt.Sprintf("需要一个布尔值, 但值是 %s", describe(value))
+		}
+
+	case "string":
+		switch rv.Kind() {
+		case reflect.Map, reflect.Slice, reflect.Array:
+			return fmt.Sprintf("需要一个字符串, 但值是 %s", describe(value))
+		}
+		str := fmt.Sprint(value)
+		if s.Pattern != "" {
+			if ok, err := regexp.MatchString(s.Pattern, str); err == nil && !ok {
+				return fmt.Sprintf("值 %q 的格式不正确, 例如时间间隔应该写成 3s、1m30s", str)
+			}
+		}
+		if s.MinLength != nil && len(str) < *s.MinLength {
+			return fmt.Sprintf("长度不能小于 %d", *s.MinLength)
+		}
+		if s.MaxLength != nil && len(str) > *s.MaxLength {
+			return fmt.Sprintf("长度不能大于 %d", *s.MaxLength)
+		}
+		return c.checkEnum(s, value)
+	}
+	return ""
+}
+
+func (c *checker) checkEnum(s *Schema, value any) string {
+	if len(s.Enum) == 0 {
+		return ""
+	}
+
+	options := make([]string, len(s.Enum))
+	for i, e := range s.Enum {
+		options[i] = fmt.Sprint(e)
+	}
+	if slices.Contains(options, fmt.Sprint(value)) {
+		return ""
+	}
+	return fmt.Sprintf("必须是 [%s] 之一, 但值是 %v", strings.Join(options, " "), value)
+}
+
+// checkLogger 检查 weaver.logger 中只能取特定值的字段, 参见 runtime/logger。
+func (c *checker) checkLogger(settings map[string]any) {
+	section, ok := settings[systemConfigKey].(map[string]any)
+	if !ok {
+		return
+	}
+	logger, ok := section["logger"].(map[string]any)
+	if !ok {
+		return
+	}
+
+	for key, options := range map[string][]string{
+		"level": {"debug", "info", "warn", "error"},
+		"type":  {"console", "text", "json"},
+	} {
+		v, ok := logger[key].(string)
+		if !ok || v == "" {
+			continue
+		}
+		if !slices.Contains(options, strings.ToLower(v)) {
+			c.report(join(systemConfigKey+".logger", key), "必须是 [%s] 之一(不区分大小写), 但值是 %q", strings.Join(options, " "), v)
+		}
+	}
+}
+
+func schemaTypes(s *Schema) []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	default:
+		return nil
+	}
+}
+
+func describe(value any) string {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Map:
+		return "一个对象"
+	case reflect.Slice, reflect.Array:
+		return "一个数组"
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
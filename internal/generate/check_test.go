@@ -0,0 +1,120 @@
+package generate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/jun3372/weaver/internal/config"
+)
+
+func TestCheckConfig(t *testing.T) {
+	schema, err := ConfigSchema(".", []string{"./testdata/schema"}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name   string
+		config string
+		want   []string
+	}{
+		{
+			name: "valid",
+			config: `
+db:
+  primary:
+    source: postgresql://localhost/db
+    type: mysql
+    timeout: 1m30s
+    hosts: a,b
+    pool: {size: "20"}
+    labels: {a: 1}
+weaver:
+  logger: {level: DEBUG, type: json}
+`,
+		},
+		{
+			name: "dotted component names",
+			config: `
+db: {primary: {source: x}}
+weaver:
+  components:
+    wechat.T: {enabled: false}
+    github.com/x/app/user/User: {impl: redis}
+`,
+		},
+		{
+			name:   "missing key",
+			config: "weaver: {logger: {level: info}}\n",
+			want:   []string{"db.primary: 缺少配置键, 组件 schema.Store 使用了它"},
+		},
+		{
+			name: "unused keys",
+			config: `
+db:
+  primary: {source: x, appid: wx123}
+  replica: {source: y}
+wechat: {appsecret: abc}
+`,
+			want: []string{
+				"db.primary.appid: 未使用的配置键, 没有字段读取它",
+				"db.replica: 未使用的配置键, 没有字段读取它",
+				"wechat: 未使用的顶层配置键, 没有组件读取它",
+			},
+		},
+		{
+			name: "decode failures",
+			config: `
+db:
+  primary:
+    type: sqlite
+    timeout: 3 seconds
+    hosts: {a: b}
+    pool: {size: 101}
+    labels: {a: x}
+`,
+			want: []string{
+				"db.primary.hosts: 需要一个数组",
+				"db.primary.labels.a: 需要一个数字",
+				"db.primary.pool.size: 不能大于 100",
+				"db.primary.source: 缺少必填的配置",
+				"db.primary.timeout: 值 \"3 seconds\" 的格式不正确",
+				"db.primary.type: 必须是 [postgres mysql] 之一",
+			},
+		},
+		{
+			name: "logger",
+			config: `
+db: {primary: {source: x}}
+weaver:
+  logger: {level: verbose, type: xml, addsource: maybe, levle: debug}
+`,
+			want: []string{
+				"weaver.logger.addsource: 需要一个布尔值",
+				"weaver.logger.level: 必须是 [debug info warn error] 之一",
+				"weaver.logger.levle: 未使用的配置键",
+				"weaver.logger.type: 必须是 [console text json] 之一",
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			conf := viper.New()
+			conf.SetConfigType("yaml")
+			if err := conf.ReadConfig(strings.NewReader(test.config)); err != nil {
+				t.Fatal(err)
+			}
+
+			problems := CheckConfig(schema, config.Settings(conf))
+			if len(problems) != len(test.want) {
+				t.Fatalf("CheckConfig() = %v, want %d problems", problems, len(test.want))
+			}
+			for i, want := range test.want {
+				if got := problems[i].String(); !strings.HasPrefix(got, want) {
+					t.Errorf("problem %d = %q, want prefix %q", i, got, want)
+				}
+			}
+		})
+	}
+}
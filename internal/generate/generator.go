@@ -29,6 +29,7 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -113,7 +114,7 @@ func Generate(dir string, pkgs []string, opt Options) error {
 	}
 	fset := token.NewFileSet()
 	cfg := &packages.Config{
-		Mode:      packages.NeedName | packages.NeedSyntax | packages.NeedImports | packages.NeedTypes | packages.NeedTypesInfo,
+		Mode:      packages.NeedName | packages.NeedSyntax | packages.NeedImports | packages.NeedDeps | packages.NeedTypes | packages.NeedTypesInfo,
 		Dir:       dir,
 		Fset:      fset,
 		ParseFile: parseNonWeaverGenFile,
@@ -128,12 +129,23 @@ func Generate(dir string, pkgs []string, opt Options) error {
 
 	var automarshals typeutil.Map
 	var errs []error
+	var generators []*generator
 	for _, pkg := range pkgList {
 		g, err := newGenerator(opt, pkg, fset, &automarshals)
 		if err != nil {
 			errs = append(errs, err)
 			continue
 		}
+		generators = append(generators, g)
+	}
+
+	// The runtime instantiates a component only after all of the components
+	// it references, so a cycle of weaver.Ref fields can never be wired.
+	if err := checkRefCycles(fset, generators); err != nil {
+		return err
+	}
+
+	for _, g := range generators {
 		if err := g.generate(); err != nil {
 			errs = append(errs, err)
 		}
@@ -141,6 +153,70 @@ func Generate(dir string, pkgs []string, opt Options) error {
 	return errors.Join(errs...)
 }
 
+// checkRefCycles returns an error if the weaver.Ref fields of the components
+// found in the provided generators form a cycle. Components outside of the
+// loaded packages are treated as leaves, since their references are unknown.
+//
+// A component interface with multiple implementations may be wired to any of
+// them, so the references of all implementations are followed.
+func checkRefCycles(fset *token.FileSet, generators []*generator) error {
+	components := map[string][]*component{}
+	for _, g := range generators {
+		for _, c := range g.components {
+			components[c.fullIntfName()] = append(components[c.fullIntfName()], c)
+		}
+	}
+
+	names := maps.Keys(components)
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	var stack []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			i := slices.Index(stack, name)
+			return append(slices.Clone(stack[i:]), name)
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, c := range components[name] {
+			for _, ref := range c.refs {
+				if cycle := visit(fullName(ref)); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = visited
+		return nil
+	}
+
+	for _, name := range names {
+		cycle := visit(name)
+		if cycle == nil {
+			continue
+		}
+
+		path := make([]string, len(cycle))
+		for i, name := range cycle {
+			path[i] = codegen.ShortName(name)
+		}
+		return errorf(fset, components[cycle[0]][0].impl.Obj().Pos(),
+			"component dependency cycle detected: %s", strings.Join(path, " -> "))
+	}
+	return nil
+}
+
 // parseNonWeaverGenFile parses a Go file, except for weaver_gen.go files whose
 // contents are ignored since those contents may reference types that no longer
 // exist.
@@ -245,18 +321,26 @@ func newGenerator(opt Options, pkg *packages.Package, fset *token.FileSet, autom
 
 		for _, c := range fileComponents {
 			// Check for component duplicates, two components that embed the
-			// same weaver.Implements[T].
+			// same weaver.Implements[T] with the same implementation name.
+			// Implementations with different weaver:"impl=<name>" tags are
+			// alternatives, one of which is selected at runtime.
 			//
 			// TODO(mwhittaker): This code relies on the fact that a component
 			// interface and component implementation have to be in the same
 			// package. If we lift this requirement, then this code will break.
-			if existing, ok := components[c.fullIntfName()]; ok {
-				errs = append(errs, errorf(pkg.Fset, c.impl.Obj().Pos(),
-					"Duplicate implementation for component %s, other declaration: %v",
-					c.fullIntfName(), fset.Position(existing.impl.Obj().Pos())))
+			if existing, ok := components[c.key()]; ok {
+				if c.implTag == "" {
+					errs = append(errs, errorf(pkg.Fset, c.impl.Obj().Pos(),
+						"Duplicate implementation for component %s, other declaration: %v. Use a weaver:\"impl=<name>\" tag on weaver.Implements to declare multiple implementations.",
+						c.fullIntfName(), fset.Position(existing.impl.Obj().Pos())))
+				} else {
+					errs = append(errs, errorf(pkg.Fset, c.impl.Obj().Pos(),
+						"Duplicate implementation %q for component %s, other declaration: %v",
+						c.implTag, c.fullIntfName(), fset.Position(existing.impl.Obj().Pos())))
+				}
 				continue
 			}
-			components[c.fullIntfName()] = c
+			components[c.key()] = c
 		}
 	}
 
@@ -324,6 +408,13 @@ func findComponents(opt Options, pkg *packages.Package, f *ast.File, tset *typeS
 }
 
 func findMethodAttributes(pkg *packages.Package, f *ast.File, components map[string]*component) error {
+	// Method attributes apply to the component interface, and hence to all
+	// of its implementations.
+	byIntf := map[string][]*component{}
+	for _, c := range components {
+		byIntf[c.fullIntfName()] = append(byIntf[c.fullIntfName()], c)
+	}
+
 	// Look for declarations of the form:
 	//	var _ weaver.NotRetriable = Component.Method
 	var errs []error
@@ -347,24 +438,27 @@ func findMethodAttributes(pkg *packages.Package, f *ast.File, components map[str
 			}
 			for _, val := range valspec.Values {
 				// We allow non-blank vars for uniformity.
-				comp, method, ok := findComponentMethod(pkg, components, val)
+				comps, method, ok := findComponentMethod(pkg, byIntf, val)
 				if !ok {
 					errs = append(errs, errorf(pkg.Fset, valspec.Pos(), "weaver.NonRetriable should only be assigned a value that identifies a method of a component implemented by this package"))
 					continue
 				}
-				if comp.noretry == nil {
-					comp.noretry = map[string]struct{}{}
+				for _, comp := range comps {
+					if comp.noretry == nil {
+						comp.noretry = map[string]struct{}{}
+					}
+					comp.noretry[method] = struct{}{}
 				}
-				comp.noretry[method] = struct{}{}
 			}
 		}
 	}
 	return errors.Join(errs...)
 }
 
-// findComponentMethod returns the component and method if val is an expression of
-// the form C.M where C is a component listed in components and C has a method named M.
-func findComponentMethod(pkg *packages.Package, components map[string]*component, val ast.Expr) (*component, string, bool) {
+// findComponentMethod returns the implementations of component C and method M
+// if val is an expression of the form C.M where C is a component listed in
+// components and C has a method named M.
+func findComponentMethod(pkg *packages.Package, components map[string][]*component, val ast.Expr) ([]*component, string, bool) {
 	sel, ok := val.(*ast.SelectorExpr)
 	if !ok {
 		return nil, "", false
@@ -378,14 +472,14 @@ func findComponentMethod(pkg *packages.Package, components map[string]*component
 		return nil, "", false
 	}
 	cname := fullName(ctype)
-	c, ok := components[cname]
+	comps, ok := components[cname]
 	if !ok {
 		return nil, "", false
 	}
 	method := sel.Sel.Name
-	for _, m := range c.methods() {
+	for _, m := range comps[0].methods() {
 		if m.Name() == method {
-			return c, method, true
+			return comps, method, true
 		}
 	}
 	return nil, "", false
@@ -501,6 +595,7 @@ func extractComponent(opt Options, pkg *packages.Package, file *ast.File, tset *
 	var intf *types.Named   // The component interface type
 	var router *types.Named // Router type (if any)
 	var isMain bool         // Is intf weaver.Main?
+	var implTag string      // Implementation name from a weaver:"impl=<name>" tag
 	var refs []*types.Named // T for which weaver.Ref[T] exists in struct
 	var listeners []string  // Names of all listener fields declared in struct
 	for _, f := range s.Fields.List {
@@ -564,6 +659,15 @@ func extractComponent(opt Options, pkg *packages.Package, file *ast.File, tset *
 			}
 			intf = named
 
+			var err error
+			if implTag, err = getImplNameFromStructField(pkg, f); err != nil {
+				return nil, err
+			}
+			if isMain && implTag != "" {
+				return nil, errorf(pkg.Fset, f.Pos(),
+					"weaver.Implements[weaver.Main] cannot have an implementation name.")
+			}
+
 		// The field f is an embedded weaver.WithRouter[T].
 		case isWeaverWithRouter(t):
 			// Check that T is a named type inside the package.
@@ -619,6 +723,7 @@ func extractComponent(opt Options, pkg *packages.Package, file *ast.File, tset *
 		impl:      impl,
 		router:    router,
 		isMain:    isMain,
+		implTag:   implTag,
 		refs:      refs,
 		listeners: listeners,
 	}
@@ -635,6 +740,27 @@ func extractComponent(opt Options, pkg *packages.Package, file *ast.File, tset *
 	return comp, nil
 }
 
+// getImplNameFromStructField extracts the implementation name from the
+// weaver:"impl=<name>" tag of the given embedded weaver.Implements field. It
+// returns an empty name if the field has no weaver tag.
+func getImplNameFromStructField(pkg *packages.Package, f *ast.Field) (string, error) {
+	if f.Tag == nil {
+		return "", nil
+	}
+	tag := reflect.StructTag(strings.TrimPrefix(
+		strings.TrimSuffix(f.Tag.Value, "`"), "`"))
+	value, ok := tag.Lookup("weaver")
+	if !ok {
+		return "", nil
+	}
+	name, ok := strings.CutPrefix(value, "impl=")
+	if !ok || !token.IsIdentifier(name) {
+		return "", errorf(pkg.Fset, f.Pos(),
+			"weaver.Implements tag %s is not of the form weaver:\"impl=<name>\", where <name> is a valid Go identifier", tag)
+	}
+	return name, nil
+}
+
 // getListenerNamesFromStructField extracts listener names from the given
 // weaver.Listener field in the component implementation struct.
 func getListenerNamesFromStructField(pkg *packages.Package, f *ast.Field) ([]string, error) {
@@ -686,6 +812,7 @@ type component struct {
 	routingKey    types.Type          // routing key, or nil if there is no router
 	routedMethods map[string]bool     // the set of methods with a routing function
 	isMain        bool                // intf is weaver.Main
+	implTag       string              // implementation name from a weaver:"impl=<name>" tag, if any
 	refs          []*types.Named      // List of T where a weaver.Ref[T] field is in impl struct
 	listeners     []string            // Names of listener fields declared in impl struct
 	noretry       map[string]struct{} // Methods that should not be retried
@@ -710,6 +837,15 @@ func (c *component) fullIntfName() string {
 	return fullName(c.intf)
 }
 
+// key uniquely identifies the component implementation among all
+// implementations of the same interface.
+func (c *component) key() string {
+	if c.implTag == "" {
+		return c.fullIntfName()
+	}
+	return c.fullIntfName() + "#" + c.implTag
+}
+
 // methods returns the component interface's methods.
 func (c *component) methods() []*types.Func {
 	underlying := c.intf.Underlying().(*types.Interface)
@@ -814,7 +950,11 @@ func (g *generator) generate() error {
 
 	// Process components in deterministic order.
 	sort.Slice(g.components, func(i, j int) bool {
-		return g.components[i].intfName() < g.components[j].intfName()
+		x, y := g.components[i], g.components[j]
+		if x.intfName() != y.intfName() {
+			return x.intfName() < y.intfName()
+		}
+		return x.implTag < y.implTag
 	})
 
 	// Generate the file body.
@@ -824,6 +964,7 @@ func (g *generator) generate() error {
 			fmt.Fprintln(&body, fmt.Sprintf(format, args...))
 		}
 		g.generateRegisteredComponents(fn)
+		g.generateLocalStubs(fn)
 		g.generateReflectStubs(fn)
 		// append the size methods
 		if g.sizeFuncNeeded.Len() > 0 {
@@ -932,35 +1073,37 @@ func (g *generator) generateRegisteredComponents(p printFn) {
 		var b strings.Builder
 
 		// Emits initializer for a single method's MethodMetrics object.
-		emitMetricInitializer := func(m *types.Func, remote bool) {
-			fmt.Fprintf(&b, ", %sMetrics: %s(%s{Caller: caller, Component: %q, Method: %q, Remote: %v, Generated: true})",
+		emitMetricInitializer := func(m *types.Func) {
+			fmt.Fprintf(&b, ", %sMetrics: %s(%s{Caller: caller, Component: %q, Method: %q})",
 				notExported(m.Name()),
 				g.codegen().qualify("MethodMetricsFor"),
 				g.codegen().qualify("MethodLabels"),
 				comp.fullIntfName(),
 				m.Name(),
-				remote,
 			)
 		}
 
 		// E.g.,
-		//   func(impl any, caller string, tracer trace.Tracer) any {
-		//       return foo_local_stub{impl: impl.(Foo), tracer: tracer, ...}
+		//   func(invoke codegen.Invoker, caller string) any {
+		//       return foo_local_stub{invoke: invoke, barMetrics: ...}
 		//   }
-		b.Reset()
-		for _, m := range comp.methods() {
-			emitMetricInitializer(m, false)
+		//
+		// weaver.Main cannot be referenced by other components, so it has no
+		// local stub.
+		var localStubFn string
+		if !comp.isMain {
+			b.Reset()
+			for _, m := range comp.methods() {
+				emitMetricInitializer(m)
+			}
+			localStubFn = fmt.Sprintf(`func(invoke %s, caller string) any { return %s_local_stub{invoke: invoke%s} }`,
+				g.codegen().qualify("Invoker"), notExported(comp.intfName()), b.String())
 		}
-		// localStubFn := fmt.Sprintf(`func(impl any, caller string, tracer %v) any { return %s_local_stub{impl: impl.(%s), tracer: tracer%s } }`, g.trace().qualify("Tracer"), notExported(name), g.componentRef(comp), b.String())
 
 		// E.g.,
 		//   func(stub *codegen.Stub, caller string) any {
 		//       return Foo_stub{stub: stub, ...}
 		//   }
-		b.Reset()
-		for _, m := range comp.methods() {
-			emitMetricInitializer(m, true)
-		}
 		// clientStubFn := fmt.Sprintf(`func(stub %s, caller string) any { return %s_client_stub{stub: stub%s } }`,
 		// g.codegen().qualify("Stub"), notExported(name), b.String())
 
@@ -1002,20 +1145,25 @@ func (g *generator) generateRegisteredComponents(p printFn) {
 		//   https://pkg.go.dev/reflect#example-TypeOf
 		p(`		Interface: %s((*%s)(nil)).Elem(),`, reflect.qualify("TypeOf"), g.componentRef(comp))
 		p(`		Impl: %s(%s{}),`, reflect.qualify("TypeOf"), comp.implName())
+		if comp.implTag != "" {
+			p(`		ImplName: %q,`, comp.implTag)
+		}
 		// if comp.router != nil {
 		// p(`		Routed: true,`)
 		// }
-		// if len(comp.listeners) > 0 {
-		// 	listeners := make([]string, len(comp.listeners))
-		// 	for i, lis := range comp.listeners {
-		// 		listeners[i] = fmt.Sprintf("%q", lis)
-		// 	}
-		// 	p(`		Listeners: []string{%s},`, strings.Join(listeners, ", "))
-		// }
+		if len(comp.listeners) > 0 {
+			listeners := make([]string, len(comp.listeners))
+			for i, lis := range comp.listeners {
+				listeners[i] = fmt.Sprintf("%q", lis)
+			}
+			p(`		Listeners: []string{%s},`, strings.Join(listeners, ", "))
+		}
 		// if len(comp.noretry) > 0 {
 		// 	p(`		NoRetry: []int{%s},`, noRetryString(comp))
 		// }
-		// p(`		LocalStubFn: %s,`, localStubFn)
+		if localStubFn != "" {
+			p(`		LocalStubFn: %s,`, localStubFn)
+		}
 		// p(`		ClientStubFn: %s,`, clientStubFn)
 		// p(`		ServerStubFn: %s,`, serverStubFn)
 		// p(`		ReflectStubFn: %s,`, reflectStubFn)
@@ -1349,6 +1497,159 @@ func (g *generator) generateSizeFunction(p printFn, t types.Type) {
 	}
 }
 
+// generateLocalStubs generates the local stubs returned by
+// Registration.LocalStubFn. A local stub implements a component interface by
+// forwarding every method call to a codegen.Invoker, which in turn calls the
+// method on a component implementation. Implementations of the same interface
+// share a single stub.
+func (g *generator) generateLocalStubs(p printFn) {
+	seen := map[string]bool{}
+	for _, comp := range g.components {
+		if comp.isMain || seen[comp.intfName()] {
+			continue
+		}
+		if len(seen) == 0 {
+			p(``)
+			p(`// Local stub implementations.`)
+		}
+		seen[comp.intfName()] = true
+
+		stub := notExported(comp.intfName()) + "_local_stub"
+		p(``)
+		p(`type %s struct{`, stub)
+		p(`	invoke %s`, g.codegen().qualify("Invoker"))
+		for _, m := range comp.methods() {
+			p(`	%sMetrics *%s`, notExported(m.Name()), g.codegen().qualify("MethodMetrics"))
+		}
+		p(`}`)
+		p(``)
+		p(`// Check that %s implements the %s interface.`, stub, g.componentRef(comp))
+		p(`var _ %s = (*%s)(nil)`, g.componentRef(comp), stub)
+		for _, m := range comp.methods() {
+			g.generateLocalStubMethod(p, comp, stub, m)
+		}
+	}
+}
+
+// generateLocalStubMethod generates the method m of a local stub. Unlike the
+// other stubs, local stubs support methods without a leading context.Context
+// or a trailing error.
+//
+// E.g.,
+//
+//	func (s foo_local_stub) Bar(ctx context.Context, a0 int) (r0 string, err error) {
+//		begin := s.barMetrics.Begin()
+//		results, err := s.invoke(ctx, "Bar", []any{a0}, func(ctx context.Context, impl any) ([]any, error) {
+//			r0, err := impl.(Foo).Bar(ctx, a0)
+//			return []any{r0}, err
+//		})
+//		s.barMetrics.End(begin, err != nil)
+//		if len(results) == 1 {
+//			r0, _ = results[0].(string)
+//		}
+//		return
+//	}
+func (g *generator) generateLocalStubMethod(p printFn, comp *component, stub string, m *types.Func) {
+	ctxType := g.tset.importPackage("context", "context").qualify("Context")
+	sig := m.Type().(*types.Signature)
+	params, results := sig.Params(), sig.Results()
+	hasCtx := params.Len() > 0 && isContext(params.At(0).Type())
+	hasErr := results.Len() > 0 && isError(results.At(results.Len()-1).Type())
+
+	// Arguments, excluding the leading context.Context.
+	var decls, args, uses []string
+	if hasCtx {
+		decls = append(decls, "ctx "+ctxType)
+		uses = append(uses, "ctx")
+	}
+	first := 0
+	if hasCtx {
+		first = 1
+	}
+	for i := first; i < params.Len(); i++ {
+		name := fmt.Sprintf("a%d", i-first)
+		at := params.At(i).Type()
+		if sig.Variadic() && i == params.Len()-1 {
+			// For variadic functions, the final argument is guaranteed to be
+			// a slice. Instead of passing an argument of type []t, we pass ...t.
+			decls = append(decls, fmt.Sprintf("%s ...%s", name, g.tset.genTypeString(at.(*types.Slice).Elem())))
+			uses = append(uses, name+"...")
+		} else {
+			decls = append(decls, fmt.Sprintf("%s %s", name, g.tset.genTypeString(at)))
+			uses = append(uses, name)
+		}
+		args = append(args, name)
+	}
+
+	// Results, excluding the trailing error.
+	n := results.Len()
+	if hasErr {
+		n--
+	}
+	var rets, names []string
+	for i := 0; i < n; i++ {
+		rets = append(rets, fmt.Sprintf("r%d %s", i, g.tset.genTypeString(results.At(i).Type())))
+		names = append(names, fmt.Sprintf("r%d", i))
+	}
+	if hasErr {
+		rets = append(rets, "err error")
+	}
+
+	returns := ""
+	if len(rets) > 0 {
+		returns = fmt.Sprintf("(%s)", strings.Join(rets, ", "))
+	}
+	p(``)
+	p(`func (s %s) %s(%s) %s {`, stub, m.Name(), strings.Join(decls, ", "), returns)
+	if !hasCtx {
+		p(`	ctx := %s()`, g.tset.importPackage("context", "context").qualify("Background"))
+	}
+
+	// The error returned by invoke is recorded in the method metrics even if
+	// the method has no trailing error.
+	metrics := notExported(m.Name()) + "Metrics"
+	p(`	begin := s.%s.Begin()`, metrics)
+	var lhs string
+	switch {
+	case n > 0:
+		lhs = "results, err := "
+	case hasErr:
+		lhs = "_, err = "
+	default:
+		lhs = "_, err := "
+	}
+	p(`	%ss.invoke(ctx, %q, []any{%s}, func(ctx %s, impl any) ([]any, error) {`, lhs, m.Name(), strings.Join(args, ", "), ctxType)
+
+	call := fmt.Sprintf("impl.(%s).%s(%s)", g.componentRef(comp), m.Name(), strings.Join(uses, ", "))
+	switch {
+	case n > 0 && hasErr:
+		p(`		%s, err := %s`, strings.Join(names, ", "), call)
+		p(`		return []any{%s}, err`, strings.Join(names, ", "))
+	case n > 0:
+		p(`		%s := %s`, strings.Join(names, ", "), call)
+		p(`		return []any{%s}, nil`, strings.Join(names, ", "))
+	case hasErr:
+		p(`		return nil, %s`, call)
+	default:
+		p(`		%s`, call)
+		p(`		return nil, nil`)
+	}
+	p(`	})`)
+	p(`	s.%s.End(begin, err != nil)`, metrics)
+
+	if n > 0 {
+		p(`	if len(results) == %d {`, n)
+		for i := 0; i < n; i++ {
+			p(`		r%d, _ = results[%d].(%s)`, i, i, g.tset.genTypeString(results.At(i).Type()))
+		}
+		p(`	}`)
+	}
+	if len(rets) > 0 {
+		p(`	return`)
+	}
+	p(`}`)
+}
+
 // generateServerStubs generates code that creates server stubs for the registered components.
 func (g *generator) generateServerStubs(p printFn) {
 	p(``)
@@ -0,0 +1,24 @@
+package generate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRefCycles(t *testing.T) {
+	for _, test := range []struct {
+		pkg  string
+		want string
+	}{
+		{"./testdata/cycle/self", "component dependency cycle detected: self.Node -> self.Node"},
+		{"./testdata/cycle/pair", "component dependency cycle detected: pair.A -> pair.B -> pair.A"},
+		{"./testdata/cycle/named", "component dependency cycle detected: named.A -> named.B -> named.A"},
+	} {
+		t.Run(test.pkg, func(t *testing.T) {
+			err := Generate(".", []string{test.pkg}, Options{})
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("Generate(%s) error = %v, want %q", test.pkg, err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,482 @@
+package generate
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/jun3372/weaver/internal/config"
+	"github.com/jun3372/weaver/runtime/codegen"
+)
+
+const (
+	SchemaUsage = `Print the JSON Schema of a Service Weaver config file.
+
+Usage:
+  weaver config schema [--tags taglist] [-o file] [packages]
+
+Description:
+  "weaver config schema" finds every weaver.WithConfig[T] field in the provided
+  packages, together with its conf, config or weaver tag, and prints a JSON
+  Schema that describes the combined config file, including the "weaver"
+  section. Types, nested structs, default and validate tags, and field comments
+  are all reflected in the schema. Packages are specified in the same way as for
+  "weaver generate".
+
+Examples:
+  # Print the schema for the package in the current directory.
+  weaver config schema
+
+  # Write the schema for all packages to weaver.schema.json.
+  weaver config schema -o weaver.schema.json ./...`
+
+	// systemConfigKey 是框架自身配置所在的配置键。
+	systemConfigKey = "weaver"
+)
+
+// Schema 是 JSON Schema (draft 2020-12) 的一个子集, 足以描述 weaver 的配置文件。
+type Schema struct {
+	SchemaURI            string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 any                `json:"type,omitempty"` // string 或 []string
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+
+	// Component 是使用该配置键的组件, 只用于顶层配置键, 不会输出到 JSON 中。
+	Component []string `json:"-"`
+}
+
+// ConfigField 是组件中的一个 weaver.WithConfig[T] 字段。
+type ConfigField struct {
+	Component string     // 组件接口的全名, 例如 github.com/x/app/user/User
+	Key       string     // 配置键, 例如 user
+	Type      types.Type // T
+	Pos       token.Position
+}
+
+// ConfigSchema 加载 pkgs 中的包, 返回描述其中所有组件配置的 JSON Schema。
+func ConfigSchema(dir string, pkgs []string, opt Options) (*Schema, error) {
+	fields, comments, system, err := FindConfigs(dir, pkgs, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &schemaBuilder{comments: comments, visiting: map[types.Type]bool{}}
+	root := &Schema{
+		SchemaURI:  "https://json-schema.org/draft/2020-12/schema",
+		Title:      "weaver config",
+		Type:       "object",
+		Properties: map[string]*Schema{},
+	}
+	if system != nil {
+		s := b.schema(system)
+		s.Description = "weaver 框架自身的配置"
+		root.Properties[systemConfigKey] = s
+	}
+
+	for _, f := range fields {
+		s := b.schema(f.Type)
+		s.Component = []string{f.Component}
+		if s.Description == "" {
+			s.Description = fmt.Sprintf("组件 %s 的配置", codegen.ShortName(f.Component))
+		}
+
+		// 配置键中的 . 表示嵌套, 例如 db.primary
+		parent := root
+		parts := strings.Split(strings.ToLower(f.Key), ".")
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := parent.Properties[part]
+			if !ok {
+				child = &Schema{Type: "object", Properties: map[string]*Schema{}}
+				parent.Properties[part] = child
+			}
+			if child.Properties == nil {
+				child.Properties = map[string]*Schema{}
+			}
+			parent = child
+		}
+
+		last := parts[len(parts)-1]
+		if existing, ok := parent.Properties[last]; ok {
+			// 多个组件使用同一个配置键, 它们的配置类型可能不同, 合并所有字段
+			mergeSchema(existing, s)
+			continue
+		}
+		parent.Properties[last] = s
+	}
+	return root, nil
+}
+
+// FindConfigs 加载 pkgs 中的包, 返回其中所有组件的 weaver.WithConfig[T] 字段, 按配置键排序。
+// comments 是所有已加载包中结构体字段的注释, system 是框架自身的配置类型 config.Config。
+func FindConfigs(dir string, pkgs []string, opt Options) (fields []ConfigField, comments map[token.Pos]string, system types.Type, err error) {
+	fset := token.NewFileSet()
+	cfg := &packages.Config{
+		Mode:      packages.NeedName | packages.NeedSyntax | packages.NeedImports | packages.NeedDeps | packages.NeedTypes | packages.NeedTypesInfo,
+		Dir:       dir,
+		Fset:      fset,
+		ParseFile: parseNonWeaverGenFile,
+	}
+	if len(opt.BuildTags) > 0 {
+		cfg.BuildFlags = []string{"-tags", opt.BuildTags}
+	}
+	pkgList, err := packages.Load(cfg, pkgs...)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("packages.Load: %w", err)
+	}
+
+	var errs []error
+	for _, pkg := range pkgList {
+		for _, err := range pkg.Errors {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, nil, nil, fmt.Errorf("%v", errs)
+	}
+
+	comments = map[token.Pos]string{}
+	packages.Visit(pkgList, nil, func(pkg *packages.Package) {
+		for _, file := range pkg.Syntax {
+			collectFieldComments(file, comments)
+		}
+		if pkg.PkgPath == weaverPackagePath+"/internal/config" && pkg.Types != nil {
+			if obj := pkg.Types.Scope().Lookup("Config"); obj != nil {
+				system = obj.Type()
+			}
+		}
+	})
+
+	for _, pkg := range pkgList {
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			s, ok := tn.Type().Underlying().(*types.Struct)
+			if !ok {
+				continue
+			}
+			fields = append(fields, componentConfigs(fset, s)...)
+		}
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].Key != fields[j].Key {
+			return fields[i].Key < fields[j].Key
+		}
+		return fields[i].Component < fields[j].Component
+	})
+	return fields, comments, system, nil
+}
+
+// componentConfigs 返回组件实现 s 中的 weaver.WithConfig[T] 字段, s 不是组件时返回 nil。
+func componentConfigs(fset *token.FileSet, s *types.Struct) []ConfigField {
+	var intf string
+	for i := 0; i < s.NumFields(); i++ {
+		if t := s.Field(i).Type(); s.Field(i).Embedded() && isWeaverImplements(t) {
+			if named, ok := t.(*types.Named).TypeArgs().At(0).(*types.Named); ok && named.Obj().Pkg() != nil {
+				intf = fullName(named)
+			}
+		}
+	}
+	if intf == "" {
+		return nil
+	}
+
+	var fields []ConfigField
+	for i := 0; i < s.NumFields(); i++ {
+		f := s.Field(i)
+		if !isWeaverType(f.Type(), "WithConfig", 1) {
+			continue
+		}
+
+		// 与运行时一样按 config.Tags() 的顺序查找配置键, 没有配置键的字段不会被注入
+		tag := reflect.StructTag(s.Tag(i))
+		var key string
+		for _, name := range config.Tags() {
+			if key = tag.Get(name); key != "" {
+				break
+			}
+		}
+		if key == "" {
+			continue
+		}
+
+		fields = append(fields, ConfigField{
+			Component: intf,
+			Key:       key,
+			Type:      f.Type().(*types.Named).TypeArgs().At(0),
+			Pos:       fset.Position(f.Pos()),
+		})
+	}
+	return fields
+}
+
+// collectFieldComments 记录 file 中所有结构体字段的文档注释或行尾注释, 以字段名的位置为键。
+func collectFieldComments(file *ast.File, comments map[token.Pos]string) {
+	ast.Inspect(file, func(n ast.Node) bool {
+		st, ok := n.(*ast.StructType)
+		if !ok {
+			return true
+		}
+		for _, f := range st.Fields.List {
+			text := strings.TrimSpace(f.Doc.Text())
+			if text == "" {
+				text = strings.TrimSpace(f.Comment.Text())
+			}
+			if text == "" {
+				continue
+			}
+			text = strings.Join(strings.Fields(text), " ")
+
+			if len(f.Names) == 0 {
+				comments[embeddedIdent(f.Type).Pos()] = text
+			}
+			for _, name := range f.Names {
+				comments[name.Pos()] = text
+			}
+		}
+		return true
+	})
+}
+
+// embeddedIdent 返回嵌入字段类型 e 中的类型名, go/types 以它的位置作为嵌入字段的位置。
+func embeddedIdent(e ast.Expr) ast.Expr {
+	for {
+		switch x := e.(type) {
+		case *ast.StarExpr:
+			e = x.X
+		case *ast.IndexExpr:
+			e = x.X
+		case *ast.IndexListExpr:
+			e = x.X
+		case *ast.SelectorExpr:
+			return x.Sel
+		default:
+			return e
+		}
+	}
+}
+
+type schemaBuilder struct {
+	comments map[token.Pos]string
+	visiting map[types.Type]bool // 用于处理递归类型
+}
+
+func (b *schemaBuilder) schema(t types.Type) *Schema {
+	if isDuration(t) {
+		return &Schema{
+			Type:    []string{"string", "integer"},
+			Pattern: `^-?([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$|^0$`,
+		}
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return &Schema{Type: "boolean"}
+		case u.Info()&types.IsInteger != 0:
+			s := &Schema{Type: "integer"}
+			if u.Info()&types.IsUnsigned != 0 {
+				s.Minimum = ptr(0.0)
+			}
+			return s
+		case u.Info()&types.IsFloat != 0:
+			return &Schema{Type: "number"}
+		case u.Info()&types.IsString != 0:
+			return &Schema{Type: "string"}
+		}
+		return &Schema{}
+
+	case *types.Pointer:
+		return b.schema(u.Elem())
+
+	case *types.Slice:
+		if isByteSlice(t) {
+			return &Schema{Type: "string"}
+		}
+		return &Schema{Type: "array", Items: b.schema(u.Elem())}
+
+	case *types.Array:
+		n := int(u.Len())
+		return &Schema{Type: "array", Items: b.schema(u.Elem()), MaxItems: &n}
+
+	case *types.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schema(u.Elem())}
+
+	case *types.Struct:
+		if b.visiting[t] {
+			return &Schema{Type: "object"}
+		}
+		b.visiting[t] = true
+		defer delete(b.visiting, t)
+
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		b.addFields(s, u)
+		return s
+
+	default:
+		// interface 等类型可以是任意值
+		return &Schema{}
+	}
+}
+
+// addFields 将结构体 st 的字段加入 s, 字段名与 config.Decode 的规则一致: mapstructure 标签或小写的字段名。
+func (b *schemaBuilder) addFields(s *Schema, st *types.Struct) {
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		if !f.Exported() {
+			continue
+		}
+
+		tag := reflect.StructTag(st.Tag(i))
+		name, opts, _ := strings.Cut(tag.Get("mapstructure"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Embedded() && strings.Contains(opts, "squash") {
+			if inner, ok := f.Type().Underlying().(*types.Struct); ok {
+				b.addFields(s, inner)
+				continue
+			}
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name())
+		}
+
+		fs := b.schema(f.Type())
+		fs.Description = b.comments[f.Pos()]
+		if def, ok := tag.Lookup(config.DefaultTag); ok {
+			fs.Default = defaultValue(fs, def)
+		}
+		if rules, ok := tag.Lookup(config.ValidateTag); ok {
+			if applyRules(fs, rules) {
+				s.Required = append(s.Required, name)
+			}
+		}
+		s.Properties[name] = fs
+	}
+}
+
+// defaultValue 将 default 标签的值转换为 JSON 中对应类型的值, 规则与 config.SetDefaults 一致。
+func defaultValue(s *Schema, def string) any {
+	switch s.Type {
+	case "boolean":
+		if v, err := strconv.ParseBool(def); err == nil {
+			return v
+		}
+	case "integer", "number":
+		if v, err := strconv.ParseFloat(def, 64); err == nil {
+			return v
+		}
+	case "array":
+		items := []any{}
+		for _, item := range strings.Split(def, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, defaultValue(s.Items, item))
+			}
+		}
+		return items
+	case "object":
+		if s.AdditionalProperties != nil {
+			m := map[string]any{}
+			for _, item := range strings.Split(def, ",") {
+				if k, v, ok := strings.Cut(strings.TrimSpace(item), "="); ok {
+					m[k] = defaultValue(s.AdditionalProperties, v)
+				}
+			}
+			return m
+		}
+	}
+	return def
+}
+
+// applyRules 将 validate 标签中的规则转换为 JSON Schema 的约束, 返回字段是否是必填的。
+func applyRules(s *Schema, rules string) (required bool) {
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "required":
+			required = true
+		case "oneof":
+			for _, option := range strings.Fields(arg) {
+				s.Enum = append(s.Enum, defaultValue(s, option))
+			}
+		case "min", "max":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				// time.Duration 的限制(例如 1s)无法用 JSON Schema 表示
+				continue
+			}
+			switch s.Type {
+			case "string":
+				if name == "min" {
+					s.MinLength = ptr(int(n))
+				} else {
+					s.MaxLength = ptr(int(n))
+				}
+			case "array":
+				if name == "min" {
+					s.MinItems = ptr(int(n))
+				} else {
+					s.MaxItems = ptr(int(n))
+				}
+			case "integer", "number":
+				if name == "min" {
+					s.Minimum = ptr(n)
+				} else {
+					s.Maximum = ptr(n)
+				}
+			}
+		}
+	}
+	return required
+}
+
+// mergeSchema 将 src 的字段合并到 dst 中。
+func mergeSchema(dst, src *Schema) {
+	dst.Component = append(dst.Component, src.Component...)
+	for name, p := range src.Properties {
+		if _, ok := dst.Properties[name]; !ok {
+			if dst.Properties == nil {
+				dst.Properties = map[string]*Schema{}
+			}
+			dst.Properties[name] = p
+		}
+	}
+	for _, r := range src.Required {
+		if !slices.Contains(dst.Required, r) {
+			dst.Required = append(dst.Required, r)
+		}
+	}
+}
+
+func isDuration(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "time" && named.Obj().Name() == "Duration"
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,41 @@
+package generate
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestConfigSchema(t *testing.T) {
+	schema, err := ConfigSchema(".", []string{"./testdata/schema"}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := schema.Properties[systemConfigKey]; !ok {
+		t.Errorf("schema has no %q section", systemConfigKey)
+	}
+
+	db := schema.Properties["db"].Properties["primary"]
+	if db == nil {
+		t.Fatalf("schema has no db.primary section")
+	}
+	got, err := json.Marshal(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"description":"组件 schema.Store 的配置","type":"object","properties":{` +
+		`"db_type":{"type":"string"},` +
+		`"hosts":{"type":"array","items":{"type":"string"},"default":["a","b"]},` +
+		`"labels":{"type":"object","additionalProperties":{"type":"integer"}},` +
+		`"pool":{"type":"object","properties":{` +
+		`"next":{"type":"object"},` +
+		`"size":{"description":"连接池大小","type":"integer","default":10,"minimum":0,"maximum":100}}},` +
+		`"source":{"description":"Source 是数据库连接串","type":"string"},` +
+		`"timeout":{"type":["string","integer"],"default":"3s","pattern":"^-?([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$|^0$"},` +
+		`"type":{"type":"string","enum":["postgres","mysql"],"default":"postgres"}},` +
+		`"required":["source"]}`
+	if string(got) != want {
+		t.Fatalf("schema =\n%s\nwant\n%s", got, want)
+	}
+}
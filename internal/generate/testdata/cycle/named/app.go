@@ -0,0 +1,24 @@
+// Package named 是组件的多个实现中只有一个参与相互引用的测试数据。
+package named
+
+import (
+	"github.com/jun3372/weaver"
+)
+
+type A interface{}
+
+type B interface{}
+
+type a struct {
+	weaver.Implements[A]
+	b weaver.Ref[B]
+}
+
+type remoteA struct {
+	weaver.Implements[A] `weaver:"impl=remote"`
+}
+
+type b struct {
+	weaver.Implements[B]
+	a weaver.Ref[A]
+}
@@ -0,0 +1,20 @@
+// Package pair 是两个组件相互引用的测试数据。
+package pair
+
+import (
+	"github.com/jun3372/weaver"
+)
+
+type A interface{}
+
+type B interface{}
+
+type a struct {
+	weaver.Implements[A]
+	b weaver.Ref[B]
+}
+
+type b struct {
+	weaver.Implements[B]
+	a weaver.Ref[A]
+}
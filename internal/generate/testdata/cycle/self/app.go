@@ -0,0 +1,13 @@
+// Package self 是组件引用自身的测试数据。
+package self
+
+import (
+	"github.com/jun3372/weaver"
+)
+
+type Node interface{}
+
+type node struct {
+	weaver.Implements[Node]
+	self weaver.Ref[Node]
+}
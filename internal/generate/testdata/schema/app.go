@@ -0,0 +1,32 @@
+// Package schema 是 ConfigSchema 的测试数据。
+package schema
+
+import (
+	"time"
+
+	"github.com/jun3372/weaver"
+)
+
+type Store interface{}
+
+type options struct {
+	// Source 是数据库连接串
+	Source  string        `validate:"required"`
+	Type    string        `default:"postgres" validate:"oneof=postgres mysql"`
+	Timeout time.Duration `default:"3s"`
+	Hosts   []string      `default:"a,b"`
+	Pool    pool
+	Alias   string `mapstructure:"db_type"`
+	Labels  map[string]int
+	ignored int
+}
+
+type pool struct {
+	Size uint `default:"10" validate:"max=100"` // 连接池大小
+	Next *pool
+}
+
+type store struct {
+	weaver.Implements[Store]
+	weaver.WithConfig[options] `conf:"db.primary"`
+}
@@ -0,0 +1,22 @@
+// Package private contains hooks that the weaver package exposes to other
+// packages in this module, e.g. weavertest, without making them public API.
+package private
+
+import (
+	"context"
+	"reflect"
+)
+
+// Options configures a Run invocation.
+type Options struct {
+	Config string               // 配置内容, YAML 格式; 为空时不加载配置
+	Fakes  map[reflect.Type]any // 组件接口类型 -> 替代该组件的实现
+}
+
+// Run instantiates the main component of type main and its dependencies,
+// starts them, calls app with the main component and shuts everything down
+// once app returns. Unlike weaver.Run it does not parse flags or install
+// signal handlers.
+//
+// Run is set by the weaver package.
+var Run func(ctx context.Context, opts Options, main reflect.Type, app func(context.Context, any) error) error
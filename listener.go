@@ -0,0 +1,135 @@
+package weaver
+
+import (
+	stderrors "errors"
+	"net"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Listener 是组件的网络监听器。运行时在调用组件的 Init 之前, 为组件中每个 weaver.Listener 类型的字段
+// 按 weaver.listeners.<name>.address 打开一个 TCP 监听器, name 是字段的 weaver 标签, 默认为字段名:
+//
+//	type server struct {
+//		weaver.Implements[weaver.Main]
+//		api   weaver.Listener                    // weaver.listeners.api.address
+//		admin weaver.Listener `weaver:"internal"` // weaver.listeners.internal.address
+//	}
+//
+//	func (s *server) Start(ctx context.Context) error {
+//		return http.Serve(s.api, handler)
+//	}
+//
+// 没有配置地址时监听 localhost 上的随机端口。进程通过 socket activation 启动时(LISTEN_FDS 和 LISTEN_FDNAMES),
+// 名称相同的继承的 socket 优先于配置的地址。应用关闭时, 监听器在所有组件关闭之后按打开的逆序关闭。
+type Listener struct {
+	net.Listener
+}
+
+// listenerType 是 weaver.Listener 的类型。
+var listenerType = reflect.TypeOf(Listener{})
+
+// namedListener 是运行时为组件打开的监听器。
+type namedListener struct {
+	name      string // 监听器的名称
+	component string // 声明监听器的组件
+	lis       net.Listener
+}
+
+// WithListeners 为组件 obj 中每个 weaver.Listener 类型的字段打开监听器, component 是组件的实例名。
+func (w *widget) WithListeners(obj any, component string) error {
+	s := reflect.ValueOf(obj).Elem()
+	t := s.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type != listenerType {
+			continue
+		}
+
+		name := f.Tag.Get("weaver")
+		if name == "" {
+			name = f.Name
+		}
+		lis, err := w.listen(name, component)
+		if err != nil {
+			return errors.Errorf("component %q: %v", component, err)
+		}
+
+		field := s.Field(i)
+		reflect.NewAt(field.Type(), field.Addr().UnsafePointer()).Elem().Set(reflect.ValueOf(Listener{Listener: lis}))
+	}
+	return nil
+}
+
+// listen 为组件 component 打开名为 name 的监听器。监听器的名称在整个应用中必须唯一。
+func (w *widget) listen(name, component string) (net.Listener, error) {
+	for _, l := range w.listeners {
+		if strings.EqualFold(l.name, name) {
+			return nil, errors.Errorf("监听器 %q 已被组件 %q 使用", name, l.component)
+		}
+	}
+
+	// 通过 socket activation 继承的同名监听器优先于配置的地址, 进程管理器可以在重启期间保持 socket 打开
+	lis, ok, err := activatedListener(name)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		w.listeners = append(w.listeners, &namedListener{name: name, component: component, lis: lis})
+		w.logger("weaver").Info("使用继承的监听器", "listener", name, "component", component, "address", lis.Addr().String())
+		return lis, nil
+	}
+
+	addr := w.listenerAddress(name)
+	if addr == "" {
+		addr = "localhost:0"
+	}
+	lis, err = net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.Errorf("监听器 %q 监听 %s 失败: %v", name, addr, err)
+	}
+
+	w.listeners = append(w.listeners, &namedListener{name: name, component: component, lis: lis})
+	w.logger("weaver").Info("监听器已打开", "listener", name, "component", component, "address", lis.Addr().String())
+	return lis, nil
+}
+
+// listenerAddress 返回 weaver.listeners.<name>.address 配置的地址。配置的键由 viper 转换为小写, 因此不区分大小写。
+func (w *widget) listenerAddress(name string) string {
+	for key, lis := range w.option.Listeners {
+		if strings.EqualFold(key, name) {
+			return lis.Address
+		}
+	}
+	return ""
+}
+
+// closeListeners 按打开的逆序关闭所有监听器。组件已经关闭的监听器不视为错误。
+func (w *widget) closeListeners() error {
+	var errs []error
+	for i := len(w.listeners) - 1; i >= 0; i-- {
+		l := w.listeners[i]
+		if err := l.lis.Close(); err != nil && !stderrors.Is(err, net.ErrClosed) {
+			errs = append(errs, errors.Errorf("failed to close listener %q: %v", l.name, err))
+		}
+	}
+	w.listeners = nil
+	return stderrors.Join(errs...)
+}
+
+// listenerAddrs 返回组件 component 的监听器名称和实际监听的地址。
+func (w *widget) listenerAddrs(component string) map[string]string {
+	var addrs map[string]string
+	for _, l := range w.listeners {
+		if l.component != component {
+			continue
+		}
+		if addrs == nil {
+			addrs = map[string]string{}
+		}
+		addrs[l.name] = l.lis.Addr().String()
+	}
+	return addrs
+}
@@ -0,0 +1,123 @@
+package weaver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/jun3372/weaver/runtime/codegen"
+)
+
+type listenerComponent interface{}
+
+type listenerServer struct {
+	Implements[listenerComponent]
+	api      Listener
+	internal Listener `weaver:"debug"`
+
+	initAddrs []string // Init 时看到的监听地址
+}
+
+func (s *listenerServer) Init(context.Context) error {
+	s.initAddrs = []string{s.api.Addr().String(), s.internal.Addr().String()}
+	return nil
+}
+
+type duplicateListeners struct {
+	Implements[listenerComponent]
+	a Listener `weaver:"api"`
+	b Listener `weaver:"API"`
+}
+
+func newListenerWidget(t *testing.T, yaml string, impl any) *widget {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	conf := viper.New()
+	conf.SetConfigType("yaml")
+	if err := conf.ReadConfig(strings.NewReader(yaml)); err != nil {
+		t.Fatal(err)
+	}
+	return newWidget(ctx, cancel, newViperProvider(conf, nil), []*codegen.Registration{
+		{Name: "test/listener", Interface: reflect.TypeOf((*listenerComponent)(nil)).Elem(), Impl: reflect.TypeOf(impl)},
+	})
+}
+
+func TestListeners(t *testing.T) {
+	w := newListenerWidget(t, `
+weaver:
+  listeners:
+    API:
+      address: 127.0.0.1:0
+`, listenerServer{})
+
+	obj, err := w.getImpl(reflect.TypeOf(listenerServer{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := obj.(*listenerServer)
+	if len(s.initAddrs) != 2 || !strings.HasPrefix(s.initAddrs[0], "127.0.0.1:") {
+		t.Fatalf("addresses seen by Init = %v, want api on 127.0.0.1", s.initAddrs)
+	}
+
+	var names []string
+	for _, l := range w.listeners {
+		names = append(names, l.name)
+	}
+	if want := []string{"api", "debug"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("listeners = %v, want %v", names, want)
+	}
+	if got := w.componentsInfo().Instantiated[0].Listeners; got["api"] != s.initAddrs[0] || got["debug"] != s.initAddrs[1] {
+		t.Fatalf("componentsInfo listeners = %v, want api=%s debug=%s", got, s.initAddrs[0], s.initAddrs[1])
+	}
+
+	conn, err := net.Dial("tcp", s.api.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	if err := w.shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, lis := range []Listener{s.api, s.internal} {
+		if _, err := lis.Accept(); !errors.Is(err, net.ErrClosed) {
+			t.Errorf("Accept on %s after shutdown = %v, want net.ErrClosed", lis.Addr(), err)
+		}
+	}
+}
+
+func TestListenerErrors(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+
+	for _, test := range []struct {
+		name string
+		yaml string
+		impl any
+		want string
+	}{
+		{"duplicate", "weaver: {}", duplicateListeners{}, `监听器 "API" 已被组件 "test/listener" 使用`},
+		{"address in use", "weaver: {listeners: {api: {address: " + taken.Addr().String() + "}}}", listenerServer{}, `监听器 "api" 监听 ` + taken.Addr().String() + " 失败"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			w := newListenerWidget(t, test.yaml, test.impl)
+			_, err := w.getImpl(reflect.TypeOf(test.impl))
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("getImpl error = %v, want %q", err, test.want)
+			}
+			if err := w.closeListeners(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
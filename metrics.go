@@ -0,0 +1,214 @@
+package weaver
+
+import (
+	"net/url"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/jun3372/weaver/runtime/codegen"
+	"github.com/jun3372/weaver/runtime/metrics"
+)
+
+// registrations 返回用于确定指标所属组件的组件注册信息, 测试中可以替换。
+var registrations = codegen.Registered
+
+// componentLabels 在指标的标签前加上 component 标签。
+type componentLabels[L comparable] struct {
+	Component string
+	Labels    L
+}
+
+// metricFamily 是一组同名的指标, 所有指标都带有 component 标签,
+// 值是定义指标的包中的组件全名。由于指标通常在组件注册之前定义, component 标签在第一次记录时才确定。
+type metricFamily[L comparable] struct {
+	metrics *metrics.MetricMap[componentLabels[L]]
+	pkg     string
+
+	once      sync.Once
+	component string
+}
+
+func newMetricFamily[L comparable](typ metrics.MetricType, name, help string, bounds []float64) *metricFamily[L] {
+	return &metricFamily[L]{
+		metrics: metrics.RegisterMap[componentLabels[L]](typ, name, help, bounds),
+		pkg:     callerPackage(3),
+	}
+}
+
+func (f *metricFamily[L]) get(labels L) *metrics.Metric {
+	f.once.Do(func() { f.component = metricComponent(f.pkg, registrations()) })
+	return f.metrics.Get(componentLabels[L]{Component: f.component, Labels: labels})
+}
+
+// lazyMetric 在第一次使用时才从 family 中取出没有标签的指标; family 为 nil 时直接使用 metric。
+type lazyMetric struct {
+	family *metricFamily[struct{}]
+	once   sync.Once
+	metric *metrics.Metric
+}
+
+func (l *lazyMetric) get() *metrics.Metric {
+	if l.family == nil {
+		return l.metric
+	}
+	l.once.Do(func() { l.metric = l.family.get(struct{}{}) })
+	return l.metric
+}
+
+// callerPackage 返回调用栈中第 skip 层函数所在的包路径。
+func callerPackage(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip)
+	if !ok {
+		return ""
+	}
+	return funcPackage(runtime.FuncForPC(pc).Name())
+}
+
+// funcPackage 返回函数全名 name 中的包路径, name 例如 github.com/x/app/user.init 或 github.com/x/app/user.(*user).Get。
+// 链接器会转义包路径最后一段中的 ".", 例如 example.com/foo.v2 中的函数名为 example.com/foo%2ev2.Func,
+// 因此最后一个 "/" 之后的第一个 "." 总是包路径的结尾, 截取之后再还原转义的字符。
+func funcPackage(name string) string {
+	slash := strings.LastIndex(name, "/")
+	if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+		name = name[:slash+1+dot]
+	}
+	if pkg, err := url.PathUnescape(name); err == nil {
+		return pkg
+	}
+	return name
+}
+
+// metricComponent 返回实现位于包 pkg 中的组件全名。包中没有组件或者有多个组件时返回包路径。
+func metricComponent(pkg string, regs []*codegen.Registration) string {
+	component := ""
+	for _, reg := range regs {
+		if reg.Impl.PkgPath() != pkg || reg.Name == component {
+			continue
+		}
+		if component != "" {
+			return pkg
+		}
+		component = reg.Name
+	}
+	if component == "" {
+		return pkg
+	}
+	return component
+}
+
+// Counter 是只增不减的指标, 例如处理的订单数。
+type Counter struct {
+	metric lazyMetric
+}
+
+// NewCounter 注册并返回名为 name 的 Counter。通常在包级别的变量中定义指标:
+//
+//	var orders = weaver.NewCounter("orders_total", "处理的订单数")
+//
+// 指标和运行时的方法指标位于同一个进程级别的注册表中, 可以通过管理服务的 /metrics 采集,
+// 并带有 component 标签, 值是定义指标的包中的组件全名。名称重复或者不合法时 panic。
+func NewCounter(name, help string) *Counter {
+	return &Counter{metric: lazyMetric{family: newMetricFamily[struct{}](metrics.Counter, name, help, nil)}}
+}
+
+// Inc 将 Counter 加 1。
+func (c *Counter) Inc() { c.metric.get().Add(1) }
+
+// Add 将 Counter 加上 delta, delta 为负数时忽略。
+func (c *Counter) Add(delta float64) { c.metric.get().Add(delta) }
+
+// Get 返回 Counter 的值。
+func (c *Counter) Get() float64 { return c.metric.get().Get() }
+
+// CounterMap 是一组以 L 作为标签的 Counter。L 必须是结构体, 导出的字段是字符串、布尔值或整数,
+// 标签名是首字母小写的字段名, 可以用 `weaver:"name"` 标签指定。
+//
+//	type orderLabels struct {
+//		Channel string
+//		Paid    bool
+//	}
+//	var orders = weaver.NewCounterMap[orderLabels]("orders_total", "处理的订单数")
+//
+//	orders.Get(orderLabels{Channel: "web", Paid: true}).Inc()
+type CounterMap[L comparable] struct {
+	family *metricFamily[L]
+}
+
+// NewCounterMap 注册并返回名为 name 的 CounterMap, 参见 NewCounter。
+func NewCounterMap[L comparable](name, help string) *CounterMap[L] {
+	return &CounterMap[L]{family: newMetricFamily[L](metrics.Counter, name, help, nil)}
+}
+
+// Get 返回标签为 labels 的 Counter。
+func (m *CounterMap[L]) Get(labels L) *Counter {
+	return &Counter{metric: lazyMetric{metric: m.family.get(labels)}}
+}
+
+// Gauge 是可增可减的指标, 例如队列的长度。
+type Gauge struct {
+	metric lazyMetric
+}
+
+// NewGauge 注册并返回名为 name 的 Gauge, 参见 NewCounter。
+func NewGauge(name, help string) *Gauge {
+	return &Gauge{metric: lazyMetric{family: newMetricFamily[struct{}](metrics.Gauge, name, help, nil)}}
+}
+
+// Set 设置 Gauge 的值。
+func (g *Gauge) Set(value float64) { g.metric.get().Set(value) }
+
+// Add 将 Gauge 加上 delta。
+func (g *Gauge) Add(delta float64) { g.metric.get().Add(delta) }
+
+// Sub 将 Gauge 减去 delta。
+func (g *Gauge) Sub(delta float64) { g.metric.get().Sub(delta) }
+
+// Get 返回 Gauge 的值。
+func (g *Gauge) Get() float64 { return g.metric.get().Get() }
+
+// GaugeMap 是一组以 L 作为标签的 Gauge, 参见 CounterMap。
+type GaugeMap[L comparable] struct {
+	family *metricFamily[L]
+}
+
+// NewGaugeMap 注册并返回名为 name 的 GaugeMap, 参见 NewCounter。
+func NewGaugeMap[L comparable](name, help string) *GaugeMap[L] {
+	return &GaugeMap[L]{family: newMetricFamily[L](metrics.Gauge, name, help, nil)}
+}
+
+// Get 返回标签为 labels 的 Gauge。
+func (m *GaugeMap[L]) Get(labels L) *Gauge {
+	return &Gauge{metric: lazyMetric{metric: m.family.get(labels)}}
+}
+
+// Histogram 记录值的分布, 例如请求的耗时。
+type Histogram struct {
+	metric lazyMetric
+}
+
+// NewHistogram 注册并返回名为 name 的 Histogram, bounds 是严格递增的桶上界,
+// 大于所有上界的值记录在 +Inf 桶中。参见 NewCounter。
+//
+//	var latency = weaver.NewHistogram("checkout_latency_ms", "下单耗时(毫秒)", []float64{10, 50, 100, 500, 1000})
+func NewHistogram(name, help string, bounds []float64) *Histogram {
+	return &Histogram{metric: lazyMetric{family: newMetricFamily[struct{}](metrics.Histogram, name, help, bounds)}}
+}
+
+// Put 记录一个值。
+func (h *Histogram) Put(value float64) { h.metric.get().Put(value) }
+
+// HistogramMap 是一组以 L 作为标签的 Histogram, 参见 CounterMap。
+type HistogramMap[L comparable] struct {
+	family *metricFamily[L]
+}
+
+// NewHistogramMap 注册并返回名为 name 的 HistogramMap, 参见 NewHistogram。
+func NewHistogramMap[L comparable](name, help string, bounds []float64) *HistogramMap[L] {
+	return &HistogramMap[L]{family: newMetricFamily[L](metrics.Histogram, name, help, bounds)}
+}
+
+// Get 返回标签为 labels 的 Histogram。
+func (m *HistogramMap[L]) Get(labels L) *Histogram {
+	return &Histogram{metric: lazyMetric{metric: m.family.get(labels)}}
+}
@@ -0,0 +1,124 @@
+package weaver
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jun3372/weaver/runtime/codegen"
+	"github.com/jun3372/weaver/runtime/metrics"
+)
+
+type orderLabels struct {
+	Channel string
+	Paid    bool `weaver:"is_paid"`
+}
+
+var (
+	testOrders    = NewCounter("test_orders_total", "Orders processed")
+	testQueue     = NewGauge("test_queue_length", "Orders waiting")
+	testLatency   = NewHistogram("test_checkout_latency_ms", "Checkout latency", []float64{10, 100})
+	testOrdersMap = NewCounterMap[orderLabels]("test_orders_by_channel_total", "Orders processed by channel")
+	testQueueMap  = NewGaugeMap[orderLabels]("test_queue_length_by_channel", "Orders waiting by channel")
+	testLatencies = NewHistogramMap[orderLabels]("test_checkout_latency_by_channel_ms", "Checkout latency by channel", []float64{10, 100})
+)
+
+func TestMetrics(t *testing.T) {
+	defer func(old func() []*codegen.Registration) { registrations = old }(registrations)
+	registrations = func() []*codegen.Registration {
+		return []*codegen.Registration{
+			{Name: "test/orders/Orders", Interface: reflect.TypeOf((*repoComponent)(nil)).Elem(), Impl: reflect.TypeOf(repo{})},
+			{Name: "other/Other", Interface: reflect.TypeOf((*repoComponent)(nil)).Elem(), Impl: reflect.TypeOf(strings.Builder{})},
+		}
+	}
+
+	testOrders.Inc()
+	testOrders.Add(2)
+	testQueue.Set(5)
+	testQueue.Sub(1)
+	testLatency.Put(50)
+	testOrdersMap.Get(orderLabels{Channel: "web", Paid: true}).Inc()
+	testOrdersMap.Get(orderLabels{Channel: "web", Paid: true}).Inc()
+	testOrdersMap.Get(orderLabels{Channel: "app"}).Inc()
+	testQueueMap.Get(orderLabels{Channel: "web"}).Add(3)
+	testLatencies.Get(orderLabels{Channel: "web"}).Put(500)
+
+	if got := testOrders.Get(); got != 3 {
+		t.Errorf("testOrders = %v, want 3", got)
+	}
+	if got := testQueue.Get(); got != 4 {
+		t.Errorf("testQueue = %v, want 4", got)
+	}
+
+	component := map[string]string{"component": "test/orders/Orders"}
+	snapshots := metrics.Snapshot()
+	for _, want := range []struct {
+		name   string
+		labels map[string]string
+		value  float64
+	}{
+		{"test_orders_total", component, 3},
+		{"test_queue_length", component, 4},
+		{"test_checkout_latency_ms", component, 50},
+		{"test_orders_by_channel_total", map[string]string{"component": "test/orders/Orders", "channel": "web", "is_paid": "true"}, 2},
+		{"test_orders_by_channel_total", map[string]string{"component": "test/orders/Orders", "channel": "app", "is_paid": "false"}, 1},
+		{"test_queue_length_by_channel", map[string]string{"component": "test/orders/Orders", "channel": "web"}, 3},
+		{"test_checkout_latency_by_channel_ms", map[string]string{"component": "test/orders/Orders", "channel": "web"}, 500},
+	} {
+		s := metrics.Find(snapshots, want.name, want.labels)
+		if s == nil {
+			t.Errorf("metric %s%v not found", want.name, want.labels)
+			continue
+		}
+		if s.Value != want.value {
+			t.Errorf("metric %s%v = %v, want %v", want.name, want.labels, s.Value, want.value)
+		}
+	}
+
+	var b strings.Builder
+	if err := metrics.WritePrometheus(&b, snapshots); err != nil {
+		t.Fatal(err)
+	}
+	if want := `test_orders_by_channel_total{channel="web",component="test/orders/Orders",is_paid="true"} 2`; !strings.Contains(b.String(), want) {
+		t.Errorf("output does not contain %q:\n%s", want, b.String())
+	}
+}
+
+func TestMetricComponent(t *testing.T) {
+	intf := reflect.TypeOf((*repoComponent)(nil)).Elem()
+	regs := []*codegen.Registration{
+		{Name: "test/repo", Interface: intf, Impl: reflect.TypeOf(repo{})},
+		{Name: "test/repo", Interface: intf, Impl: reflect.TypeOf(oldGreeter{}), ImplName: "next"},
+		{Name: "strings/Builder", Interface: intf, Impl: reflect.TypeOf(strings.Builder{})},
+		{Name: "strings/Reader", Interface: intf, Impl: reflect.TypeOf(strings.Reader{})},
+	}
+	for _, test := range []struct{ pkg, want string }{
+		{"github.com/jun3372/weaver", "test/repo"}, // 同一个组件的多个实现
+		{"strings", "strings"},                     // 包中有多个组件
+		{"bytes", "bytes"},                         // 包中没有组件
+	} {
+		if got := metricComponent(test.pkg, regs); got != test.want {
+			t.Errorf("metricComponent(%q) = %q, want %q", test.pkg, got, test.want)
+		}
+	}
+	if got, want := callerPackage(1), "github.com/jun3372/weaver"; got != want {
+		t.Errorf("callerPackage(1) = %q, want %q", got, want)
+	}
+}
+
+func TestFuncPackage(t *testing.T) {
+	for _, test := range []struct{ name, want string }{
+		{"main.main", "main"},
+		{"github.com/x/app/user.init", "github.com/x/app/user"},
+		{"github.com/x/app/user.(*user).Get", "github.com/x/app/user"},
+		{"github.com/x/app/user.init.func1", "github.com/x/app/user"},
+		// 链接器转义了包路径最后一段中的 "."
+		{"example.com/foo%2ev2.Func", "example.com/foo.v2"},
+		{"example.com/foo%2ev2.(*T).M", "example.com/foo.v2"},
+		{"gopkg.in/yaml%2ev3.init", "gopkg.in/yaml.v3"},
+	} {
+		if got := funcPackage(test.name); got != test.want {
+			t.Errorf("funcPackage(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package weaver
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+
+	"github.com/jun3372/weaver/internal/config"
+)
+
+// ConfigProvider 为组件提供配置, 默认的实现读取 -conf 指定的本地配置文件。
+// 使用 WithConfigProvider 可以从其他来源加载配置, 例如 provider 包中的 HTTP 和目录实现。
+// 实现必须支持并发调用。
+type ConfigProvider interface {
+	// Get 返回配置键 key 对应的原始配置, 不存在时返回 nil。key 以 . 分隔嵌套的键, 例如 weaver.logger,
+	// 与 viper 一样不区分大小写。返回的 map 和切片不会被修改, 但也不应该被调用方修改。
+	Get(key string) any
+
+	// Unmarshal 将配置键 key 对应的配置解析到 v 中。
+	Unmarshal(key string, v any) error
+
+	// Watch 监听配置的变化, 每次配置更新后调用 onChange, 此时 Get 已经返回新的配置。
+	// Watch 阻塞直到 ctx 被取消, 不支持监听的实现可以直接等待 ctx 被取消。
+	Watch(ctx context.Context, onChange func()) error
+}
+
+// WithConfigProvider 使用 p 作为配置来源, 代替 WithConfigFile 和 WithViper。
+func WithConfigProvider(p ConfigProvider) AppOption {
+	return func(o *appOptions) {
+		o.provider = p
+	}
+}
+
+// viperProvider 是基于 viper 的 ConfigProvider。files 不为 nil 时监听其中的每一层配置文件,
+// 并在变化后替换 conf; 否则使用 viper 自身的监听, 即只监听 conf 读取的配置文件。
+type viperProvider struct {
+	mu    sync.RWMutex
+	conf  *viper.Viper
+	files *config.Files
+}
+
+func newViperProvider(conf *viper.Viper, files *config.Files) *viperProvider {
+	return &viperProvider{conf: conf, files: files}
+}
+
+func (p *viperProvider) Get(key string) any {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.conf.Get(key)
+}
+
+func (p *viperProvider) Unmarshal(key string, v any) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.conf.UnmarshalKey(key, v)
+}
+
+func (p *viperProvider) Watch(ctx context.Context, onChange func()) error {
+	if p.files != nil {
+		return p.files.Watch(ctx, func(conf *viper.Viper) {
+			p.mu.Lock()
+			p.conf = conf
+			p.mu.Unlock()
+			onChange()
+		}, func(err error) {
+			slog.Error("重新加载配置文件失败", "err", err)
+		})
+	}
+
+	if p.conf.ConfigFileUsed() != "" {
+		p.conf.OnConfigChange(func(fsnotify.Event) { onChange() })
+		p.conf.WatchConfig()
+	}
+	<-ctx.Done()
+	return nil
+}
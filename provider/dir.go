@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// Dir 从目录中加载配置, 每个文件对应一个顶层配置键:
+//
+//   - 扩展名为 viper 支持的格式(yaml、json、toml 等)的文件按格式解析, 文件名去掉扩展名后即配置键,
+//     例如 user.yaml 的内容就是配置键 user 的值;
+//   - 其他文件的内容作为字符串, 去掉末尾的换行符, 文件名即配置键。
+//
+// 以 . 开头的文件和子目录会被忽略, 因此可以直接使用 Kubernetes 挂载的 ConfigMap 和 Secret 目录。
+type Dir struct {
+	settings
+	dir string
+}
+
+// NewDir 返回从目录 dir 加载配置的 Dir, 它会先同步加载一次配置, 失败时返回错误。
+func NewDir(dir string) (*Dir, error) {
+	d := &Dir{dir: dir}
+	if _, err := d.load(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Watch 监听目录, 其中的文件被修改、新增或删除后重新加载配置, 配置变化时调用 onChange, 直到 ctx 被取消。
+func (d *Dir) Watch(ctx context.Context, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(d.dir); err != nil {
+		return err
+	}
+
+	// 合并短时间内的多个事件, 例如 ConfigMap 更新时会替换多个符号链接
+	const debounce = 100 * time.Millisecond
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Warn("failed to watch config dir", "dir", d.dir, "err", err)
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			timer.Reset(debounce)
+		case <-timer.C:
+			changed, err := d.load()
+			if err != nil {
+				slog.Warn("failed to load config dir", "dir", d.dir, "err", err)
+				continue
+			}
+			if changed {
+				onChange()
+			}
+		}
+	}
+}
+
+// load 加载目录中的所有文件, 返回配置是否发生了变化。
+func (d *Dir) load() (bool, error) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return false, err
+	}
+
+	m := map[string]any{}
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		// 通过 os.Stat 跟随符号链接
+		path := filepath.Join(d.dir, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			return false, err
+		}
+		if info.IsDir() {
+			continue
+		}
+
+		ext := filepath.Ext(name)
+		if slices.Contains(viper.SupportedExts, strings.TrimPrefix(ext, ".")) {
+			v := viper.New()
+			v.SetConfigFile(path)
+			if err := v.ReadInConfig(); err != nil {
+				return false, fmt.Errorf("read config file %s: %w", path, err)
+			}
+			m[strings.ToLower(strings.TrimSuffix(name, ext))] = v.AllSettings()
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return false, err
+		}
+		m[strings.ToLower(name)] = strings.TrimRight(string(data), "\r\n")
+	}
+	return d.replace(m), nil
+}
@@ -0,0 +1,136 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HTTP 定时使用 GET 请求从 URL 拉取配置, 响应体是一个 JSON 对象, 其顶层键即配置键, 例如:
+//
+//	{"user": {"source": "postgresql://localhost/db"}, "weaver": {"logger": {"level": "debug"}}}
+//
+// 服务端返回 ETag 时, 之后的请求会携带 If-None-Match, 响应 304 表示配置没有变化。
+// 拉取失败时保留之前的配置并在下一个周期重试。
+type HTTP struct {
+	settings
+	url      string
+	client   *http.Client
+	interval time.Duration
+	header   http.Header
+
+	mu   sync.Mutex // 保护 etag
+	etag string
+}
+
+// HTTPOption 配置 HTTP。
+type HTTPOption func(*HTTP)
+
+// WithInterval 设置拉取配置的间隔, 默认为 30s。
+func WithInterval(d time.Duration) HTTPOption {
+	return func(h *HTTP) {
+		h.interval = d
+	}
+}
+
+// WithClient 使用 client 发送请求, 默认为 http.DefaultClient。
+func WithClient(client *http.Client) HTTPOption {
+	return func(h *HTTP) {
+		h.client = client
+	}
+}
+
+// WithHeader 在每个请求中添加请求头, 例如用于认证的 Authorization。
+func WithHeader(key, value string) HTTPOption {
+	return func(h *HTTP) {
+		h.header.Add(key, value)
+	}
+}
+
+// NewHTTP 返回从 url 拉取配置的 HTTP, 它会先同步拉取一次配置, 失败时返回错误。
+func NewHTTP(ctx context.Context, url string, opts ...HTTPOption) (*HTTP, error) {
+	h := &HTTP{
+		url:      url,
+		client:   http.DefaultClient,
+		interval: 30 * time.Second,
+		header:   http.Header{},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	if _, err := h.poll(ctx); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Watch 每隔一个周期拉取一次配置, 配置变化时调用 onChange, 直到 ctx 被取消。
+func (h *HTTP) Watch(ctx context.Context, onChange func()) error {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			changed, err := h.poll(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Warn("failed to poll config", "url", h.url, "err", err)
+				}
+				continue
+			}
+			if changed {
+				onChange()
+			}
+		}
+	}
+}
+
+// poll 拉取一次配置, 返回配置是否发生了变化。
+func (h *HTTP) poll(ctx context.Context) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header = h.header.Clone()
+	req.Header.Set("Accept", "application/json")
+
+	h.mu.Lock()
+	if h.etag != "" {
+		req.Header.Set("If-None-Match", h.etag)
+	}
+	h.mu.Unlock()
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return false, nil
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return false, fmt.Errorf("GET %s: %s: %s", h.url, resp.Status, body)
+	}
+
+	var m map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return false, fmt.Errorf("GET %s: decode config: %w", h.url, err)
+	}
+
+	h.mu.Lock()
+	h.etag = resp.Header.Get("ETag")
+	h.mu.Unlock()
+	return h.replace(m), nil
+}
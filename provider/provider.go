@@ -0,0 +1,47 @@
+// Package provider 包含 weaver.ConfigProvider 的参考实现:
+//
+//   - HTTP 定时从配置中心拉取 JSON 格式的配置;
+//   - Dir 从目录中加载配置, 每个文件对应一个顶层配置键, 适用于 Kubernetes ConfigMap 等挂载的配置。
+//
+// 使用 weaver.WithConfigProvider 将它们传给应用:
+//
+//	p, err := provider.NewHTTP(ctx, "http://config.local/apps/hello")
+//	if err != nil {
+//		return err
+//	}
+//	app, err := weaver.NewApp[server](weaver.WithConfigProvider(p))
+package provider
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/jun3372/weaver/internal/config"
+)
+
+// settings 是一份可以被原子替换的配置。
+type settings struct {
+	mu sync.RWMutex
+	m  map[string]any
+}
+
+func (s *settings) Get(key string) any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return config.Lookup(s.m, key)
+}
+
+func (s *settings) Unmarshal(key string, v any) error {
+	return config.Decode(s.Get(key), v)
+}
+
+// replace 使用 m 替换当前配置, 返回配置是否发生了变化。
+func (s *settings) replace(m map[string]any) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if reflect.DeepEqual(s.m, m) {
+		return false
+	}
+	s.m = m
+	return true
+}
@@ -0,0 +1,257 @@
+package provider_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jun3372/weaver"
+	"github.com/jun3372/weaver/provider"
+	"github.com/jun3372/weaver/runtime/codegen"
+)
+
+var (
+	_ weaver.ConfigProvider = (*provider.HTTP)(nil)
+	_ weaver.ConfigProvider = (*provider.Dir)(nil)
+)
+
+// configServer 是配置中心的本地替代, 按版本号返回配置并支持 ETag。
+type configServer struct {
+	mu      sync.Mutex
+	version int
+	config  map[string]any
+}
+
+func (s *configServer) set(config map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version++
+	s.config = config
+}
+
+func (s *configServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	etag := strconv.Quote(strconv.Itoa(s.version))
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	json.NewEncoder(w).Encode(s.config)
+}
+
+type options struct {
+	Source string
+	Pool   struct{ Size int }
+}
+
+func TestHTTP(t *testing.T) {
+	server := &configServer{}
+	server.set(map[string]any{"User": map[string]any{"source": "v1", "pool": map[string]any{"size": 2}}})
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p, err := provider.NewHTTP(ctx, ts.URL, provider.WithInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got options
+	if err := p.Unmarshal("user", &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Source != "v1" || got.Pool.Size != 2 {
+		t.Fatalf("Unmarshal() = %+v", got)
+	}
+	if size := p.Get("user.pool.size"); size != float64(2) {
+		t.Fatalf("Get(user.pool.size) = %v, want 2", size)
+	}
+
+	changed := make(chan struct{}, 10)
+	go p.Watch(ctx, func() { changed <- struct{}{} })
+
+	server.set(map[string]any{"user": map[string]any{"source": "v2"}})
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("config change was not detected")
+	}
+	if source := p.Get("user.source"); source != "v2" {
+		t.Fatalf("Get(user.source) = %v, want v2", source)
+	}
+
+	// 配置没有变化时服务端返回 304, 不会调用 onChange
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case <-changed:
+		t.Fatal("onChange called without a config change")
+	default:
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such app", http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	if _, err := provider.NewHTTP(context.Background(), ts.URL); err == nil {
+		t.Fatal("NewHTTP() succeeded, want error")
+	}
+}
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("user.yaml", "source: v1\npool: {size: 2}\n")
+	write("token", "s3cret\n")
+	write(".hidden", "ignored")
+
+	p, err := provider.NewDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got options
+	if err := p.Unmarshal("user", &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Source != "v1" || got.Pool.Size != 2 {
+		t.Fatalf("Unmarshal() = %+v", got)
+	}
+	if token := p.Get("token"); token != "s3cret" {
+		t.Fatalf("Get(token) = %q, want s3cret", token)
+	}
+	if hidden := p.Get(".hidden"); hidden != nil {
+		t.Fatalf("Get(.hidden) = %v, want nil", hidden)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan struct{}, 10)
+	go p.Watch(ctx, func() { changed <- struct{}{} })
+
+	// 等待监听开始: 反复写入直到检测到变化
+	deadline := time.After(5 * time.Second)
+	for done := false; !done; {
+		write("user.yaml", "source: v2\n")
+		select {
+		case <-changed:
+			done = true
+		case <-time.After(200 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("config change was not detected")
+		}
+	}
+	if source := p.Get("user.source"); source != "v2" {
+		t.Fatalf("Get(user.source) = %v, want v2", source)
+	}
+}
+
+type userComponent interface{}
+
+type user struct {
+	weaver.Implements[userComponent]
+	weaver.WithConfig[options] `conf:"user"`
+}
+
+type app struct {
+	weaver.Implements[weaver.Main]
+	user weaver.Ref[userComponent]
+}
+
+func TestAppWithHTTP(t *testing.T) {
+	server := &configServer{}
+	server.set(map[string]any{"user": map[string]any{"source": "v1"}})
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p, err := provider.NewHTTP(ctx, ts.URL, provider.WithInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	regs := []*codegen.Registration{
+		{Name: "test/app", Interface: reflect.TypeOf((*weaver.Main)(nil)).Elem(), Impl: reflect.TypeOf(app{})},
+		{Name: "test/user", Interface: reflect.TypeOf((*userComponent)(nil)).Elem(), Impl: reflect.TypeOf(user{})},
+	}
+	a, err := weaver.NewApp[app](weaver.WithRegistry(regs), weaver.WithConfigProvider(p), weaver.WithLogger(slog.Default()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Stop(context.Background())
+
+	u := a.Main().user.Get().(*user)
+	if source := u.Config().Source; source != "v1" {
+		t.Fatalf("Source = %q, want v1", source)
+	}
+
+	changed := make(chan string, 1)
+	u.OnChange(func(_, new *options) { changed <- new.Source })
+	server.set(map[string]any{"user": map[string]any{"source": "v2"}})
+	select {
+	case source := <-changed:
+		if source != "v2" {
+			t.Fatalf("Source = %q, want v2", source)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("component config was not updated")
+	}
+}
+
+func TestAppWithHTTPMixedCaseKeys(t *testing.T) {
+	t.Setenv("WEAVER_USER_SOURCE", "from-env")
+	t.Setenv("WEAVER_USER_POOL_SIZE", "8")
+
+	// 配置中心返回的键保留原始的大小写, 环境变量仍然可以覆盖它们
+	server := &configServer{}
+	server.set(map[string]any{"User": map[string]any{"Source": "remote", "Pool": map[string]any{"Size": 1}}})
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p, err := provider.NewHTTP(ctx, ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	regs := []*codegen.Registration{
+		{Name: "test/app", Interface: reflect.TypeOf((*weaver.Main)(nil)).Elem(), Impl: reflect.TypeOf(app{})},
+		{Name: "test/user", Interface: reflect.TypeOf((*userComponent)(nil)).Elem(), Impl: reflect.TypeOf(user{})},
+	}
+	a, err := weaver.NewApp[app](weaver.WithRegistry(regs), weaver.WithConfigProvider(p), weaver.WithLogger(slog.Default()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Stop(context.Background())
+
+	if got := a.Main().user.Get().(*user).Config(); got.Source != "from-env" || got.Pool.Size != 8 {
+		t.Fatalf("Config() = %+v, want source from-env and pool size 8", *got)
+	}
+}
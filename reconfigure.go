@@ -0,0 +1,149 @@
+package weaver
+
+import (
+	"context"
+	stderrors "errors"
+	"reflect"
+	"slices"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// watchConfig 在 w.conf 中的配置变化时更新组件, 直到 ctx 被取消。
+func (w *widget) watchConfig(ctx context.Context) {
+	if w.conf == nil {
+		return
+	}
+
+	go func() {
+		err := w.conf.Watch(ctx, func() {
+			w.logger("weaver").Info("配置已变化, 重新加载配置")
+			w.reload(ctx)
+		})
+		if err != nil && ctx.Err() == nil {
+			w.logger("weaver").Error("监听配置变化失败", "err", err)
+		}
+	}()
+}
+
+// reload 在配置变化后调用。它按配置键比较新旧配置, 只处理配置发生变化的组件,
+// 未通过校验的新配置会被忽略:
+//
+//   - 组件实现了 Reconfigure(ctx context.Context, old, new *T) error 时, 先以新配置调用它,
+//     返回 nil 才应用新配置, 返回错误则保留旧配置;
+//   - 新配置生效后通知通过 WithConfig.OnChange 注册的函数;
+//   - 否则单独重启该组件, 即依次调用 Shutdown、Init 和 Start。
+//
+// 最后将 weaver.components 中 traffic 的变化应用到已经创建的流量分配, 参见 reloadTrafficLocked。
+func (w *widget) reload(ctx context.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, name := range slices.Clone(w.order) {
+		if err := w.reconfigureLocked(ctx, name); err != nil {
+			w.logger("weaver").Error("组件重新加载配置失败", "component", name, "err", err)
+		}
+	}
+	w.reloadTrafficLocked()
+}
+
+// reconfigureLocked 将名为 name 的组件的配置更新为当前配置。
+//
+// REQUIRES: w.mu is held.
+func (w *widget) reconfigureLocked(ctx context.Context, name string) error {
+	obj := w.components[name]
+	var restart bool
+	var errs []error
+	for _, b := range w.bindings[name] {
+		raw, _, secrets, err := w.lookupConfig(b.key, b.holder.configType())
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if reflect.DeepEqual(raw, b.raw) {
+			continue
+		}
+
+		next := reflect.New(b.holder.configType()).Interface()
+		if err := w.decodeConfig(b.key, raw, next); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// Reconfigure 拒绝的配置不会被任何读取方看到, 因此先调用它再应用新配置
+		prev := b.holder.load()
+		hook, ok := reconfigureHook(obj, b.holder.configType())
+		if ok {
+			if err := hook(ctx, reflect.ValueOf(prev), reflect.ValueOf(next)); err != nil {
+				errs = append(errs, errors.Errorf("配置 %q 的变更被拒绝: %v", b.key, err))
+				continue
+			}
+		}
+
+		b.holder.swap(next)
+		b.raw, b.secrets = raw, secrets
+		b.holder.notify(prev, next)
+		if !ok {
+			restart = true
+			continue
+		}
+		w.logger("weaver").Info("组件配置已更新", "component", name, "key", b.key)
+	}
+
+	if restart {
+		w.restartLocked(ctx, name)
+	}
+
+	return stderrors.Join(errs...)
+}
+
+// reconfigureHook 返回组件针对配置类型 typ 的 Reconfigure 方法。
+func reconfigureHook(obj any, typ reflect.Type) (func(ctx context.Context, prev, next reflect.Value) error, bool) {
+	m := reflect.ValueOf(obj).MethodByName("Reconfigure")
+	if !m.IsValid() {
+		return nil, false
+	}
+
+	t := m.Type()
+	ptr := reflect.PointerTo(typ)
+	if t.NumIn() != 3 || t.In(0) != contextType || t.In(1) != ptr || t.In(2) != ptr ||
+		t.NumOut() != 1 || t.Out(0) != errorType {
+		return nil, false
+	}
+
+	return func(ctx context.Context, prev, next reflect.Value) error {
+		err, _ := m.Call([]reflect.Value{reflect.ValueOf(ctx), prev, next})[0].Interface().(error)
+		return err
+	}, true
+}
+
+// restartLocked 单独重启名为 name 的组件: 依次调用 Shutdown、Init 和 Start,
+// 组件可以在 Init 中按新配置重新创建 Shutdown 释放的资源。Init 失败时组件不会再启动。
+//
+// REQUIRES: w.mu is held.
+func (w *widget) restartLocked(ctx context.Context, name string) {
+	w.logger("weaver").Info("配置变化, 重启组件", "component", name)
+	obj := w.components[name]
+	w.stopLocked(name)
+	if i, ok := obj.(interface{ Shutdown(_ context.Context) error }); ok {
+		if err := i.Shutdown(ctx); err != nil {
+			w.logger("weaver").Error("Component failed to shutdown", "component", name, "err", err)
+		}
+	}
+
+	if i, ok := obj.(interface{ Init(_ context.Context) error }); ok {
+		if err := i.Init(w.ctx); err != nil {
+			w.states[name] = stateFailed
+			w.logger("weaver").Error("Component initialization failed", "component", name, "err", err)
+			return
+		}
+	}
+	w.states[name] = stateInitialized
+
+	w.startLocked(w.ctx, func(fn func() error) { go fn() }, name)
+}
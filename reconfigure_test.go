@@ -0,0 +1,144 @@
+package weaver
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/jun3372/weaver/runtime/codegen"
+)
+
+type limiterComponent interface{}
+type workerComponent interface{}
+
+type limiterConfig struct{ Rate int }
+type workerConfig struct{ Size int }
+
+type limiter struct {
+	Implements[limiterComponent]
+	WithConfig[limiterConfig] `conf:"limiter"`
+	calls                     int
+	seen                      []int // Reconfigure 时 Config() 返回的 Rate
+}
+
+func (l *limiter) Reconfigure(_ context.Context, old, new *limiterConfig) error {
+	l.calls++
+	l.seen = append(l.seen, l.Config().Rate)
+	if new.Rate <= 0 {
+		return errors.New("rate must be positive")
+	}
+	return nil
+}
+
+type worker struct {
+	Implements[workerComponent]
+	WithConfig[workerConfig] `conf:"worker"`
+	limiter                  Ref[limiterComponent]
+	starts, shutdowns        atomic.Int32
+	inits                    []int // Init 时 Config() 返回的 Size
+}
+
+func (w *worker) Init(context.Context) error {
+	w.inits = append(w.inits, w.Config().Size)
+	return nil
+}
+
+func (w *worker) Start(ctx context.Context) error {
+	w.starts.Add(1)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (w *worker) Shutdown(context.Context) error {
+	w.shutdowns.Add(1)
+	return nil
+}
+
+func TestReload(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conf := viper.New()
+	conf.SetConfigType("yaml")
+	read := func(config string) {
+		if err := conf.ReadConfig(strings.NewReader(config)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read("limiter: {rate: 1}\nworker: {size: 1}\n")
+
+	w := newWidget(ctx, cancel, newViperProvider(conf, nil), []*codegen.Registration{
+		{Name: "test/limiter", Interface: reflect.TypeOf((*limiterComponent)(nil)).Elem(), Impl: reflect.TypeOf(limiter{})},
+		{Name: "test/worker", Interface: reflect.TypeOf((*workerComponent)(nil)).Elem(), Impl: reflect.TypeOf(worker{})},
+	})
+	obj, err := w.getImpl(reflect.TypeOf(worker{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wk := obj.(*worker)
+	l := w.components["test/limiter"].(*limiter)
+	if err := w.start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 只有 limiter 的配置变化: 调用 Reconfigure, worker 不受影响
+	read("limiter: {rate: 2}\nworker: {size: 1}\n")
+	w.reload(ctx)
+	if l.calls != 1 || l.Config().Rate != 2 {
+		t.Fatalf("limiter calls = %d, rate = %d, want 1, 2", l.calls, l.Config().Rate)
+	}
+	if got := wk.shutdowns.Load(); got != 0 {
+		t.Fatalf("worker shutdowns = %d, want 0", got)
+	}
+
+	// Reconfigure 拒绝的变更不会生效
+	read("limiter: {rate: 0}\nworker: {size: 1}\n")
+	w.reload(ctx)
+	if l.calls != 2 || l.Config().Rate != 2 {
+		t.Fatalf("limiter calls = %d, rate = %d, want 2, 2", l.calls, l.Config().Rate)
+	}
+	// 调用 Reconfigure 时新配置还没有应用
+	if want := []int{1, 2}; !reflect.DeepEqual(l.seen, want) {
+		t.Fatalf("rates seen by Reconfigure = %v, want %v", l.seen, want)
+	}
+
+	// 没有 Reconfigure 的组件被单独重启, 并通知订阅者
+	var changes []int
+	cancelSub := wk.OnChange(func(old, new *workerConfig) {
+		changes = append(changes, old.Size, new.Size)
+	})
+	defer cancelSub()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			if size := wk.Config().Size; size != 1 && size != 3 {
+				t.Errorf("read a torn config: size = %d", size)
+				return
+			}
+		}
+	}()
+
+	read("limiter: {rate: 0}\nworker: {size: 3}\n")
+	w.reload(ctx)
+	<-done
+	if want := []int{1, 3}; !reflect.DeepEqual(changes, want) {
+		t.Fatalf("OnChange calls = %v, want %v", changes, want)
+	}
+	if wk.Config().Size != 3 || wk.shutdowns.Load() != 1 {
+		t.Fatalf("worker size = %d, shutdowns = %d, want 3, 1", wk.Config().Size, wk.shutdowns.Load())
+	}
+	// 重启时以新配置再次调用 Init
+	if want := []int{1, 3}; !reflect.DeepEqual(wk.inits, want) {
+		t.Fatalf("sizes seen by Init = %v, want %v", wk.inits, want)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("restarting a component canceled the application")
+	}
+}
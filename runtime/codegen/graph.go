@@ -17,6 +17,7 @@ package codegen
 import (
 	"crypto/sha256"
 	"fmt"
+	"path"
 	"regexp"
 	"sort"
 )
@@ -68,3 +69,14 @@ func checksumEdge(src, dst string) string {
 	sum := sha256.Sum256([]byte(edge))
 	return fmt.Sprintf("%0x", sum)[:8]
 }
+
+// ShortName returns the abbreviated form of a fully qualified component name
+// that is used in human readable messages, e.g.
+// "github.com/jun3372/weaver/examples/hello/user/User" becomes "user.User".
+func ShortName(name string) string {
+	dir, base := path.Split(name)
+	if dir == "" {
+		return base
+	}
+	return path.Base(dir) + "." + base
+}
@@ -0,0 +1,73 @@
+package codegen
+
+import (
+	"time"
+
+	"github.com/jun3372/weaver/runtime/metrics"
+)
+
+// Method metrics recorded by the local stubs generated by "weaver generate".
+var (
+	methodCounts = metrics.RegisterMap[MethodLabels](
+		metrics.Counter,
+		"weaver_method_count",
+		"Count of component method invocations",
+		nil,
+	)
+	methodErrors = metrics.RegisterMap[MethodLabels](
+		metrics.Counter,
+		"weaver_method_error_count",
+		"Count of component method invocations that returned an error",
+		nil,
+	)
+	methodLatencies = metrics.RegisterMap[MethodLabels](
+		metrics.Histogram,
+		"weaver_method_latency_micros",
+		"Duration, in microseconds, of component method execution",
+		[]float64{10, 25, 50, 100, 250, 500, 1e3, 2.5e3, 5e3, 1e4, 2.5e4, 5e4, 1e5, 2.5e5, 5e5, 1e6, 2.5e6, 5e6, 1e7},
+	)
+)
+
+// MethodLabels are the labels of the metrics recorded for a component method.
+type MethodLabels struct {
+	Caller    string // full name of the calling component
+	Component string // full name of the called component
+	Method    string // name of the called method
+}
+
+// MethodMetrics records the calls, errors and latency of a component method
+// called by a given caller.
+type MethodMetrics struct {
+	count   *metrics.Metric
+	errors  *metrics.Metric
+	latency *metrics.Metric
+}
+
+// MethodMetricsFor returns the metrics for the method identified by labels.
+func MethodMetricsFor(labels MethodLabels) *MethodMetrics {
+	return &MethodMetrics{
+		count:   methodCounts.Get(labels),
+		errors:  methodErrors.Get(labels),
+		latency: methodLatencies.Get(labels),
+	}
+}
+
+// MethodCallHandle holds information about a call started by Begin.
+type MethodCallHandle struct {
+	start time.Time
+}
+
+// Begin starts metric collection for a method call.
+func (m *MethodMetrics) Begin() MethodCallHandle {
+	return MethodCallHandle{start: time.Now()}
+}
+
+// End ends metric collection for a method call started by Begin. failed
+// reports whether the call returned an error.
+func (m *MethodMetrics) End(handle MethodCallHandle, failed bool) {
+	m.latency.Put(float64(time.Since(handle.start).Microseconds()))
+	m.count.Add(1)
+	if failed {
+		m.errors.Add(1)
+	}
+}
@@ -1,6 +1,7 @@
 package codegen
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -22,7 +23,9 @@ func Registered() []*Registration {
 	return globalRegistry.allComponents()
 }
 
-// Find returns the registration of the named component.
+// Find returns the registration of the named component. If the component has
+// multiple implementations, Find returns the default one, i.e. the one without
+// an ImplName, if any.
 func Find(name string) (*Registration, bool) {
 	return globalRegistry.find(name)
 }
@@ -32,8 +35,8 @@ func Find(name string) (*Registration, bool) {
 // to Register in init functions in code generated by "weaver generate".
 type registry struct {
 	m          sync.Mutex
-	components map[reflect.Type]*Registration // the set of registered components, by their interface types
-	byName     map[string]*Registration       // map from full component name to registration
+	components map[reflect.Type][]*Registration // the set of registered components, by their interface types
+	byName     map[string][]*Registration       // map from full component name to registrations
 }
 
 // Registration is the configuration needed to register a Service Weaver component.
@@ -41,10 +44,27 @@ type Registration struct {
 	Name      string       // full package-prefixed component name
 	Interface reflect.Type // interface type for the component
 	Impl      reflect.Type // implementation type (struct)
+	ImplName  string       // implementation name from a weaver:"impl=<name>" tag, or empty for the default implementation
 	Routed    bool         // True if calls to this component should be routed
 	Listeners []string     // the names of any weaver.Listeners
+
+	// LocalStubFn returns a stub that implements Interface by forwarding
+	// every method call to invoke and records method metrics labeled with
+	// the full name of the calling component. It is nil for code generated
+	// by older versions of "weaver generate".
+	LocalStubFn func(invoke Invoker, caller string) any
 }
 
+// Invoker invokes a method of a component on behalf of a local stub. args are
+// the method arguments, excluding the leading context.Context, if any. call
+// invokes the method on the provided component implementation and returns its
+// results, excluding the trailing error, if any.
+//
+// Methods without a leading context.Context are passed context.Background(),
+// and the error returned by an Invoker is dropped for methods without a
+// trailing error.
+type Invoker func(ctx context.Context, method string, args []any, call func(ctx context.Context, impl any) ([]any, error)) ([]any, error)
+
 func (r *registry) register(reg Registration) error {
 	if err := verifyRegistration(reg); err != nil {
 		return fmt.Errorf("Register(%q): %w", reg.Name, err)
@@ -53,16 +73,22 @@ func (r *registry) register(reg Registration) error {
 	r.m.Lock()
 	defer r.m.Unlock()
 	if r.components == nil {
-		r.components = map[reflect.Type]*Registration{}
+		r.components = map[reflect.Type][]*Registration{}
 	}
 
 	if r.byName == nil {
-		r.byName = map[string]*Registration{}
+		r.byName = map[string][]*Registration{}
+	}
+
+	for _, existing := range r.components[reg.Interface] {
+		if existing.ImplName == reg.ImplName {
+			return fmt.Errorf("Register(%q): duplicate implementation %q, other implementation: %v", reg.Name, reg.ImplName, existing.Impl)
+		}
 	}
 
 	ptr := &reg
-	r.components[reg.Interface] = ptr
-	r.byName[reg.Name] = ptr
+	r.components[reg.Interface] = append(r.components[reg.Interface], ptr)
+	r.byName[reg.Name] = append(r.byName[reg.Name], ptr)
 	return nil
 }
 
@@ -87,8 +113,8 @@ func (r *registry) allComponents() []*Registration {
 	defer r.m.Unlock()
 
 	components := make([]*Registration, 0, len(r.components))
-	for _, info := range r.components {
-		components = append(components, info)
+	for _, impls := range r.components {
+		components = append(components, impls...)
 	}
 	return components
 }
@@ -96,6 +122,14 @@ func (r *registry) allComponents() []*Registration {
 func (r *registry) find(path string) (*Registration, bool) {
 	r.m.Lock()
 	defer r.m.Unlock()
-	reg, ok := r.byName[path]
-	return reg, ok
+	impls := r.byName[path]
+	for _, reg := range impls {
+		if reg.ImplName == "" {
+			return reg, true
+		}
+	}
+	if len(impls) == 1 {
+		return impls[0], true
+	}
+	return nil, false
 }
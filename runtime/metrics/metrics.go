@@ -0,0 +1,365 @@
+// Package metrics implements the process-wide registry of the counters, gauges
+// and histograms recorded by the weaver runtime and by components.
+//
+// Every metric belongs to a family that shares a name, a type, a help string
+// and, for histograms, bucket bounds. Metrics in a family are distinguished by
+// their labels, which are described by a struct type; see RegisterMap.
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MetricType is the type of a metric.
+type MetricType int
+
+const (
+	Counter   MetricType = iota // a value that only increases
+	Gauge                       // a value that can increase and decrease
+	Histogram                   // a distribution of values over buckets
+)
+
+func (t MetricType) String() string {
+	switch t {
+	case Counter:
+		return "counter"
+	case Gauge:
+		return "gauge"
+	case Histogram:
+		return "histogram"
+	default:
+		return fmt.Sprintf("MetricType(%d)", int(t))
+	}
+}
+
+// nameRE matches valid Prometheus metric and label names.
+var nameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+var (
+	mu       sync.Mutex
+	families = map[string]*family{}
+)
+
+// family is a set of metrics with the same name.
+type family struct {
+	typ    MetricType
+	name   string
+	help   string
+	bounds []float64
+
+	mu      sync.Mutex
+	metrics []*Metric
+}
+
+// Metric is a single counter, gauge or histogram. It is safe for concurrent
+// use.
+type Metric struct {
+	family *family
+	labels []label
+
+	bits   atomic.Uint64   // the value, or the sum of the values of a histogram, as float64 bits
+	counts []atomic.Uint64 // histogram bucket counts, counts[i] counts values <= bounds[i]
+}
+
+type label struct {
+	name, value string
+}
+
+// Register registers and returns a metric without labels. It panics if a
+// metric with the same name is already registered, if the name is not a valid
+// Prometheus metric name, or if the histogram bounds are not strictly
+// increasing.
+func Register(typ MetricType, name, help string, bounds []float64) *Metric {
+	f := register(typ, name, help, bounds)
+	return f.newMetric(nil)
+}
+
+// MetricMap is a family of metrics with labels of type L. L must be a struct
+// whose exported fields are strings, booleans or integers. By default the
+// label name of a field is its name with the first letter lowercased; the
+// `weaver:"name"` struct tag overrides it. The fields of exported struct
+// fields are flattened into the labels of the enclosing struct.
+//
+//	type labels struct {
+//		Method string
+//		Status int `weaver:"status_code"`
+//	}
+type MetricMap[L comparable] struct {
+	family *family
+	fields []labelField
+
+	mu      sync.Mutex
+	metrics map[L]*Metric
+}
+
+type labelField struct {
+	index []int
+	name  string
+}
+
+// RegisterMap registers and returns a family of metrics with labels of type
+// L. It panics under the same conditions as Register, or if L is not a valid
+// label struct.
+func RegisterMap[L comparable](typ MetricType, name, help string, bounds []float64) *MetricMap[L] {
+	t := reflect.TypeFor[L]()
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("metrics: invalid labels for metric %q: %v is not a struct", name, t))
+	}
+	fields, err := labelFields(t, nil, map[string]bool{})
+	if err != nil {
+		panic(fmt.Sprintf("metrics: invalid labels for metric %q: %v", name, err))
+	}
+	return &MetricMap[L]{
+		family:  register(typ, name, help, bounds),
+		fields:  fields,
+		metrics: map[L]*Metric{},
+	}
+}
+
+// Get returns the metric with the provided labels, creating it if needed.
+func (m *MetricMap[L]) Get(labels L) *Metric {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if metric, ok := m.metrics[labels]; ok {
+		return metric
+	}
+
+	v := reflect.ValueOf(labels)
+	ls := make([]label, len(m.fields))
+	for i, f := range m.fields {
+		ls[i] = label{f.name, fmt.Sprint(v.FieldByIndex(f.index).Interface())}
+	}
+	metric := m.family.newMetric(ls)
+	m.metrics[labels] = metric
+	return metric
+}
+
+func register(typ MetricType, name, help string, bounds []float64) *family {
+	if !nameRE.MatchString(name) {
+		panic(fmt.Sprintf("metrics: invalid metric name %q", name))
+	}
+	if typ != Histogram && len(bounds) > 0 {
+		panic(fmt.Sprintf("metrics: %s %q has bucket bounds", typ, name))
+	}
+	for i := 1; i < len(bounds); i++ {
+		if bounds[i-1] >= bounds[i] {
+			panic(fmt.Sprintf("metrics: bounds of histogram %q are not strictly increasing: %v", name, bounds))
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := families[name]; ok {
+		panic(fmt.Sprintf("metrics: metric %q already registered", name))
+	}
+	f := &family{typ: typ, name: name, help: help, bounds: slices.Clone(bounds)}
+	families[name] = f
+	return f
+}
+
+func (f *family) newMetric(labels []label) *Metric {
+	m := &Metric{family: f, labels: labels}
+	if f.typ == Histogram {
+		m.counts = make([]atomic.Uint64, len(f.bounds)+1)
+	}
+	f.mu.Lock()
+	f.metrics = append(f.metrics, m)
+	f.mu.Unlock()
+	return m
+}
+
+// labelFields returns the label fields of the struct type t, whose fields are
+// at index within the labels struct.
+func labelFields(t reflect.Type, index []int, seen map[string]bool) ([]labelField, error) {
+	var fields []labelField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		fieldIndex := append(slices.Clone(index), i)
+		switch f.Type.Kind() {
+		case reflect.Struct:
+			nested, err := labelFields(f.Type, fieldIndex, seen)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, nested...)
+			continue
+		case reflect.String, reflect.Bool,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			return nil, fmt.Errorf("field %s has unsupported type %v", f.Name, f.Type)
+		}
+
+		name := f.Tag.Get("weaver")
+		if name == "" {
+			r, n := utf8.DecodeRuneInString(f.Name)
+			name = string(unicode.ToLower(r)) + f.Name[n:]
+		}
+		if !nameRE.MatchString(name) || strings.HasPrefix(name, "__") {
+			return nil, fmt.Errorf("invalid label name %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate label name %q", name)
+		}
+		seen[name] = true
+		fields = append(fields, labelField{index: fieldIndex, name: name})
+	}
+	return fields, nil
+}
+
+// Name returns the name of the metric.
+func (m *Metric) Name() string { return m.family.name }
+
+// Add adds delta to a counter or gauge. Negative deltas are ignored for
+// counters.
+func (m *Metric) Add(delta float64) {
+	if m.family.typ == Counter && delta < 0 {
+		return
+	}
+	m.add(delta)
+}
+
+// Sub subtracts delta from a gauge.
+func (m *Metric) Sub(delta float64) {
+	if m.family.typ == Gauge {
+		m.add(-delta)
+	}
+}
+
+// Set sets the value of a gauge.
+func (m *Metric) Set(value float64) {
+	if m.family.typ == Gauge {
+		m.bits.Store(math.Float64bits(value))
+	}
+}
+
+// Put records a value in a histogram.
+func (m *Metric) Put(value float64) {
+	if m.family.typ != Histogram {
+		return
+	}
+	m.counts[sort.SearchFloat64s(m.family.bounds, value)].Add(1)
+	m.add(value)
+}
+
+// Get returns the value of a counter or gauge, or the sum of the values
+// recorded by a histogram.
+func (m *Metric) Get() float64 {
+	return math.Float64frombits(m.bits.Load())
+}
+
+func (m *Metric) add(delta float64) {
+	for {
+		old := m.bits.Load()
+		if m.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+// MetricSnapshot is a snapshot of a metric.
+type MetricSnapshot struct {
+	Type   MetricType
+	Name   string
+	Help   string
+	Labels map[string]string
+
+	// Value is the value of a counter or gauge, or the sum of the values
+	// recorded by a histogram.
+	Value float64
+
+	// Bounds and Counts are the buckets of a histogram. Counts[i] is the
+	// number of values in (Bounds[i-1], Bounds[i]], and the last element of
+	// Counts is the number of values greater than all bounds.
+	Bounds []float64
+	Counts []uint64
+}
+
+// Count returns the number of values recorded by a histogram.
+func (s *MetricSnapshot) Count() uint64 {
+	var n uint64
+	for _, c := range s.Counts {
+		n += c
+	}
+	return n
+}
+
+// Snapshot returns a snapshot of all metrics, sorted by name and labels.
+func Snapshot() []*MetricSnapshot {
+	mu.Lock()
+	fs := make([]*family, 0, len(families))
+	for _, f := range families {
+		fs = append(fs, f)
+	}
+	mu.Unlock()
+
+	var snapshots []*MetricSnapshot
+	for _, f := range fs {
+		f.mu.Lock()
+		metrics := slices.Clone(f.metrics)
+		f.mu.Unlock()
+
+		for _, m := range metrics {
+			s := &MetricSnapshot{
+				Type:   f.typ,
+				Name:   f.name,
+				Help:   f.help,
+				Labels: make(map[string]string, len(m.labels)),
+				Value:  m.Get(),
+				Bounds: f.bounds,
+			}
+			for _, l := range m.labels {
+				s.Labels[l.name] = l.value
+			}
+			if f.typ == Histogram {
+				s.Counts = make([]uint64, len(m.counts))
+				for i := range m.counts {
+					s.Counts[i] = m.counts[i].Load()
+				}
+			}
+			snapshots = append(snapshots, s)
+		}
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		if snapshots[i].Name != snapshots[j].Name {
+			return snapshots[i].Name < snapshots[j].Name
+		}
+		return labelString(snapshots[i].Labels, nil) < labelString(snapshots[j].Labels, nil)
+	})
+	return snapshots
+}
+
+// Find returns the snapshot of the metric with the provided name and labels
+// in snapshots, or nil if there is none. Labels not in labels are ignored.
+func Find(snapshots []*MetricSnapshot, name string, labels map[string]string) *MetricSnapshot {
+	for _, s := range snapshots {
+		if s.Name != name {
+			continue
+		}
+		match := true
+		for k, v := range labels {
+			if s.Labels[k] != v {
+				match = false
+				break
+			}
+		}
+		if match {
+			return s
+		}
+	}
+	return nil
+}
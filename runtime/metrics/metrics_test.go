@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	type labels struct {
+		Method string
+		Status int `weaver:"status_code"`
+		Cached bool
+		ignore string
+	}
+
+	counter := Register(Counter, "test_requests_total", "Number of requests", nil)
+	gauge := Register(Gauge, "test_inflight", "Requests in flight", nil)
+	latencies := RegisterMap[labels](Histogram, "test_latency_ms", "Request latency\nin milliseconds", []float64{1, 10, 100})
+
+	counter.Add(2)
+	counter.Add(-1) // ignored for counters
+	counter.Add(1)
+	gauge.Add(5)
+	gauge.Sub(2)
+	latency := latencies.Get(labels{Method: "Get", Status: 200})
+	for _, v := range []float64{0.5, 1, 7, 1000} {
+		latency.Put(v)
+	}
+	if latencies.Get(labels{Method: "Get", Status: 200}) != latency {
+		t.Fatal("Get with the same labels returned a different metric")
+	}
+	latencies.Get(labels{Method: `a"b\c`, Status: 500, Cached: true, ignore: "x"}).Put(50)
+
+	snapshots := Snapshot()
+	if s := Find(snapshots, "test_requests_total", nil); s == nil || s.Value != 3 {
+		t.Fatalf("test_requests_total = %+v, want 3", s)
+	}
+	if s := Find(snapshots, "test_inflight", nil); s == nil || s.Value != 3 {
+		t.Fatalf("test_inflight = %+v, want 3", s)
+	}
+	s := Find(snapshots, "test_latency_ms", map[string]string{"method": "Get", "status_code": "200", "cached": "false"})
+	if s == nil || s.Value != 1008.5 || s.Count() != 4 {
+		t.Fatalf("test_latency_ms = %+v, want sum 1008.5 and count 4", s)
+	}
+
+	var b strings.Builder
+	if err := WritePrometheus(&b, snapshots); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# HELP test_requests_total Number of requests\n# TYPE test_requests_total counter\ntest_requests_total 3\n",
+		"# TYPE test_inflight gauge\ntest_inflight 3\n",
+		"# HELP test_latency_ms Request latency\\nin milliseconds\n# TYPE test_latency_ms histogram\n",
+		`test_latency_ms_bucket{cached="false",method="Get",status_code="200",le="1"} 2` + "\n",
+		`test_latency_ms_bucket{cached="false",method="Get",status_code="200",le="10"} 3` + "\n",
+		`test_latency_ms_bucket{cached="false",method="Get",status_code="200",le="+Inf"} 4` + "\n",
+		`test_latency_ms_sum{cached="false",method="Get",status_code="200"} 1008.5` + "\n",
+		`test_latency_ms_count{cached="false",method="Get",status_code="200"} 4` + "\n",
+		`test_latency_ms_count{cached="true",method="a\"b\\c",status_code="500"} 1` + "\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, b.String())
+		}
+	}
+	if strings.Count(b.String(), "# TYPE test_latency_ms ") != 1 {
+		t.Errorf("test_latency_ms has more than one TYPE line:\n%s", b.String())
+	}
+}
+
+func TestRegisterInvalid(t *testing.T) {
+	Register(Counter, "test_duplicate", "", nil)
+
+	for name, register := range map[string]func(){
+		"duplicate name":   func() { Register(Gauge, "test_duplicate", "", nil) },
+		"invalid name":     func() { Register(Counter, "test-invalid", "", nil) },
+		"counter bounds":   func() { Register(Counter, "test_counter_bounds", "", []float64{1}) },
+		"unsorted bounds":  func() { Register(Histogram, "test_unsorted", "", []float64{2, 1}) },
+		"non-struct label": func() { RegisterMap[string](Counter, "test_string_labels", "", nil) },
+		"float label":      func() { RegisterMap[struct{ Ratio float64 }](Counter, "test_float_labels", "", nil) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("register did not panic")
+				}
+			}()
+			register()
+		})
+	}
+}
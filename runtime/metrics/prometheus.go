@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WritePrometheus writes snapshots, as returned by Snapshot, in the
+// Prometheus text exposition format.
+//
+// See https://prometheus.io/docs/instrumenting/exposition_formats/.
+func WritePrometheus(w io.Writer, snapshots []*MetricSnapshot) error {
+	bw := bufio.NewWriter(w)
+	for i, s := range snapshots {
+		if i == 0 || snapshots[i-1].Name != s.Name {
+			if s.Help != "" {
+				fmt.Fprintf(bw, "# HELP %s %s\n", s.Name, escapeHelp(s.Help))
+			}
+			fmt.Fprintf(bw, "# TYPE %s %s\n", s.Name, s.Type)
+		}
+
+		if s.Type != Histogram {
+			fmt.Fprintf(bw, "%s%s %s\n", s.Name, labelString(s.Labels, nil), formatFloat(s.Value))
+			continue
+		}
+
+		// Prometheus histogram buckets are cumulative.
+		var count uint64
+		for j, c := range s.Counts {
+			count += c
+			le := math.Inf(1)
+			if j < len(s.Bounds) {
+				le = s.Bounds[j]
+			}
+			fmt.Fprintf(bw, "%s_bucket%s %d\n", s.Name, labelString(s.Labels, &label{"le", formatFloat(le)}), count)
+		}
+		fmt.Fprintf(bw, "%s_sum%s %s\n", s.Name, labelString(s.Labels, nil), formatFloat(s.Value))
+		fmt.Fprintf(bw, "%s_count%s %d\n", s.Name, labelString(s.Labels, nil), count)
+	}
+	return bw.Flush()
+}
+
+// labelString returns labels, plus extra if not nil, formatted as
+// {name="value",...} with the names sorted, or the empty string if there are
+// no labels.
+func labelString(labels map[string]string, extra *label) string {
+	if len(labels) == 0 && extra == nil {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, name, escapeLabel(labels[name]))
+	}
+	if extra != nil {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, extra.name, escapeLabel(extra.value))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// escapeLabel escapes backslashes, double quotes and line feeds in a label
+// value.
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,44 @@
+package weaver
+
+import (
+	"context"
+
+	"github.com/jun3372/weaver/internal/config"
+)
+
+// ErrSecretNotFound 表示引用的值不存在。SecretResolver 返回的错误包装了它时,
+// 会使用引用中 :- 之后的默认值, 例如 ${vault:db/password:-admin}。
+var ErrSecretNotFound = config.ErrSecretNotFound
+
+// SecretResolver 返回配置值中 ${scheme:ref} 引用的值, ref 是冒号之后的部分。
+type SecretResolver = config.Resolver
+
+// RegisterSecretResolver 注册 scheme 的解析器, 组件配置中的 ${scheme:ref} 会在注入 WithConfig 之前
+// 被替换为 resolver 返回的值。内置的解析器有:
+//
+//	${env:DB_PASSWORD}         环境变量
+//	${file:/run/secrets/db}    文件内容, 去掉末尾的换行符
+//
+// 执行命令的 ${cmd:pass show db} 默认不启用, 参见 CmdSecretResolver。
+//
+// RegisterSecretResolver 应该在 init 函数或 Run 之前调用, scheme 已经注册时会 panic。
+//
+//	func init() {
+//		weaver.RegisterSecretResolver("vault", func(ctx context.Context, ref string) (string, error) {
+//			return localVault.Get(ctx, ref)
+//		})
+//	}
+func RegisterSecretResolver(scheme string, resolver func(ctx context.Context, ref string) (string, error)) {
+	config.RegisterResolver(scheme, resolver)
+}
+
+// CmdSecretResolver 使用 sh -c 执行 ref 中的命令, 返回去掉末尾换行符的标准输出。
+// 每个命令只成功执行一次, 之后返回缓存的输出。
+//
+// 环境变量覆盖和远程配置中的值同样会被解析, 因此 ${cmd:...} 默认不启用。
+// 所有配置来源都可信时, 可以在 init 函数中启用:
+//
+//	weaver.RegisterSecretResolver("cmd", weaver.CmdSecretResolver)
+func CmdSecretResolver(ctx context.Context, ref string) (string, error) {
+	return config.ResolveCmd(ctx, ref)
+}
@@ -0,0 +1,156 @@
+package weaver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jun3372/weaver/internal/config"
+	"github.com/jun3372/weaver/runtime/codegen"
+)
+
+// tracerName 是运行时创建的 span 使用的 instrumentation 名称。
+const tracerName = "github.com/jun3372/weaver"
+
+// startTracing 按 weaver.tracing 配置创建 TracerProvider, 未配置 exporter 时不做任何事情。
+// TracerProvider 保存在 widget 中, 不会设置为全局的 TracerProvider, 同一个进程中的多个应用互不影响;
+// 组件通过 Implements.TracerProvider 获取它。
+func (w *widget) startTracing(ctx context.Context) error {
+	opt := w.option.Tracing
+	exporter, err := newSpanExporter(ctx, opt)
+	if err != nil || exporter == nil {
+		return err
+	}
+
+	sampler, err := newSampler(opt)
+	if err != nil {
+		return err
+	}
+
+	service := opt.Service
+	if service == "" {
+		service = filepath.Base(os.Args[0])
+	}
+
+	w.tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(service))),
+	)
+	w.logger("weaver").Info("已开启追踪", "exporter", opt.Exporter, "sampler", opt.Sampler)
+	return nil
+}
+
+// stopTracing 导出尚未导出的 span 并关闭 TracerProvider。
+func (w *widget) stopTracing(ctx context.Context) error {
+	if w.tracerProvider == nil {
+		return nil
+	}
+	return w.tracerProvider.Shutdown(ctx)
+}
+
+func newSpanExporter(ctx context.Context, opt config.Tracing) (sdktrace.SpanExporter, error) {
+	switch strings.ToLower(opt.Exporter) {
+	case "", "none":
+		return nil, nil
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "file":
+		f, err := os.OpenFile(opt.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, errors.Errorf("打开追踪文件失败: %v", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &fileExporter{SpanExporter: exporter, file: f}, nil
+	case "otlp":
+		if opt.Endpoint == "" {
+			return nil, errors.New("weaver.tracing.endpoint 不能为空")
+		}
+		var opts []otlptracehttp.Option
+		if strings.Contains(opt.Endpoint, "://") {
+			opts = append(opts, otlptracehttp.WithEndpointURL(opt.Endpoint))
+		} else {
+			opts = append(opts, otlptracehttp.WithEndpoint(opt.Endpoint))
+			if opt.Insecure {
+				opts = append(opts, otlptracehttp.WithInsecure())
+			}
+		}
+		return otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, errors.Errorf("不支持的 weaver.tracing.exporter %q, 可选的值: none、stdout、file、otlp", opt.Exporter)
+	}
+}
+
+func newSampler(opt config.Tracing) (sdktrace.Sampler, error) {
+	switch strings.ToLower(opt.Sampler) {
+	case "", "always":
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case "never":
+		return sdktrace.ParentBased(sdktrace.NeverSample()), nil
+	case "ratio":
+		if opt.Ratio < 0 || opt.Ratio > 1 {
+			return nil, errors.Errorf("weaver.tracing.ratio 必须在 0 到 1 之间, 但值是 %v", opt.Ratio)
+		}
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opt.Ratio)), nil
+	default:
+		return nil, errors.Errorf("不支持的 weaver.tracing.sampler %q, 可选的值: always、never、ratio", opt.Sampler)
+	}
+}
+
+// fileExporter 在关闭时同时关闭写入 span 的文件。
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file *os.File
+}
+
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	if closeErr := e.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// traced 返回为每次调用创建名为 <组件短名称>.<方法名> 的 span 的 codegen.Invoker, 方法返回的错误会记录在 span 上。
+// 没有开启追踪时直接返回 invoke。
+func (w *widget) traced(component, caller string, invoke codegen.Invoker) codegen.Invoker {
+	if w.tracerProvider == nil {
+		return invoke
+	}
+
+	tracer := w.tracerProvider.Tracer(tracerName)
+	prefix := codegen.ShortName(component) + "."
+	return func(ctx context.Context, method string, args []any, call func(context.Context, any) ([]any, error)) ([]any, error) {
+		ctx, span := tracer.Start(ctx, prefix+method,
+			trace.WithSpanKind(trace.SpanKindInternal),
+			trace.WithAttributes(
+				attribute.String("weaver.component", component),
+				attribute.String("weaver.method", method),
+				attribute.String("weaver.caller", caller),
+			),
+		)
+		defer span.End()
+
+		results, err := invoke(ctx, method, args, call)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return results, err
+	}
+}
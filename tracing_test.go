@@ -0,0 +1,161 @@
+package weaver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+
+	"github.com/jun3372/weaver/internal/config"
+)
+
+// newTracingWidget 返回按 tracing 配置开启追踪的 widget, 其中的 greeter 在参数为 mallory 时返回错误。
+func newTracingWidget(t *testing.T, tracing config.Tracing) greeterComponent {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	w := newWidget(ctx, cancel, nil, greeterRegistrations())
+	w.option.Tracing = tracing
+	w.interceptors = []Interceptor{func(ctx context.Context, call *Call, next func(context.Context) error) error {
+		if call.Args[0] == "mallory" {
+			return errors.New("permission denied")
+		}
+		return next(ctx)
+	}}
+	if err := w.startTracing(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.shutdown(context.Background()) })
+
+	obj, err := w.getImpl(reflect.TypeOf(greeterClient{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	greeter := obj.(*greeterClient).greeter.Get()
+	greeter.Greet(ctx, "alice")
+	greeter.Greet(ctx, "mallory")
+
+	if err := w.shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return greeter
+}
+
+func TestTracerProvider(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 未开启追踪时返回不记录 span 的 TracerProvider
+	w := newWidget(ctx, cancel, nil, greeterRegistrations())
+	obj, err := w.getImpl(reflect.TypeOf(greeterClient{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, span := obj.(*greeterClient).TracerProvider().Tracer("test").Start(ctx, "span"); span.IsRecording() {
+		t.Fatal("span is recording with tracing disabled")
+	}
+
+	// 开启追踪时组件得到运行时的 TracerProvider, 全局的 TracerProvider 不受影响
+	w = newWidget(ctx, cancel, nil, greeterRegistrations())
+	w.option.Tracing = config.Tracing{Exporter: "file", File: filepath.Join(t.TempDir(), "traces.json")}
+	if err := w.startTracing(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer w.shutdown(context.Background())
+	obj, err = w.getImpl(reflect.TypeOf(greeterClient{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := obj.(*greeterClient).TracerProvider(); got != w.tracerProvider {
+		t.Fatalf("TracerProvider() = %v, want the runtime's provider", got)
+	}
+	if otel.GetTracerProvider() == w.tracerProvider {
+		t.Fatal("startTracing replaced the global TracerProvider")
+	}
+}
+
+func TestTracingFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "traces.json")
+	newTracingWidget(t, config.Tracing{Exporter: "file", File: filename, Sampler: "always"})
+
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	type span struct {
+		Name   string
+		Status struct {
+			Code        string
+			Description string
+		}
+	}
+	var spans []span
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s span
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			t.Fatalf("invalid span %s: %v", scanner.Text(), err)
+		}
+		spans = append(spans, s)
+	}
+
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2: %+v", len(spans), spans)
+	}
+	for _, s := range spans {
+		if s.Name != "greeter.Greeter.Greet" {
+			t.Errorf("span name = %q, want %q", s.Name, "greeter.Greeter.Greet")
+		}
+	}
+	if spans[0].Status.Code == "Error" {
+		t.Errorf("spans[0].Status = %+v, want no error", spans[0].Status)
+	}
+	if spans[1].Status.Code != "Error" || spans[1].Status.Description != "permission denied" {
+		t.Errorf("spans[1].Status = %+v, want a permission denied error", spans[1].Status)
+	}
+}
+
+func TestTracingOTLP(t *testing.T) {
+	var requests atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-protobuf") {
+			t.Errorf("unexpected request %s %s %s", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		requests.Add(1)
+		rw.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer collector.Close()
+
+	newTracingWidget(t, config.Tracing{Exporter: "otlp", Endpoint: collector.URL + "/v1/traces", Sampler: "always"})
+	if requests.Load() == 0 {
+		t.Fatal("no spans exported to the collector")
+	}
+}
+
+func TestTracingInvalidConfig(t *testing.T) {
+	for _, tracing := range []config.Tracing{
+		{Exporter: "jaeger"},
+		{Exporter: "otlp"},
+		{Exporter: "stdout", Sampler: "sometimes"},
+		{Exporter: "stdout", Sampler: "ratio", Ratio: 2},
+	} {
+		w := newWidget(context.Background(), nil, nil, nil)
+		w.option.Tracing = tracing
+		if err := w.startTracing(context.Background()); err == nil {
+			t.Errorf("startTracing(%+v) succeeded, want an error", tracing)
+		}
+	}
+}
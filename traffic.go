@@ -0,0 +1,227 @@
+package weaver
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/jun3372/weaver/internal/config"
+	"github.com/jun3372/weaver/runtime/codegen"
+)
+
+const (
+	// maxShadowCalls 是一个组件同时进行的影子调用的上限, 达到上限时跳过新的影子调用,
+	// 避免另一个实现变慢时后台的 goroutine 无限增长。
+	maxShadowCalls = 64
+
+	// shadowTimeout 是每个影子调用的超时时间。
+	shadowTimeout = 10 * time.Second
+)
+
+// trafficSplit 按 weaver.components.<name>.traffic 配置将组件的调用分配给两个实现, 参见 config.Traffic。
+type trafficSplit struct {
+	name      string       // 组件名
+	primary   *splitTarget // weaver.components.<name>.impl 选择的实现
+	alternate *splitTarget // traffic.impl 指定的另一个实现
+	traffic   atomic.Pointer[config.Traffic]
+	shadows   chan struct{} // 限制同时进行的影子调用, 容量为 maxShadowCalls
+	log       *slog.Logger
+}
+
+// splitTarget 是参与流量分配的一个组件实现。
+type splitTarget struct {
+	reg  *codegen.Registration
+	impl string // 实现的名称, 默认实现为 default
+	obj  any
+}
+
+// invoke 是 codegen.Invoker, 按权重选择处理调用的实现, 开启 shadow 时在后台调用另一个实现并比较结果。
+func (s *trafficSplit) invoke(ctx context.Context, method string, args []any, call func(context.Context, any) ([]any, error)) ([]any, error) {
+	t := s.traffic.Load()
+	serving, other := s.primary, s.alternate
+	if t.Weight > 0 && rand.Float64()*100 < t.Weight {
+		serving, other = other, serving
+	}
+
+	results, err := call(ctx, serving.obj)
+	if t.Shadow {
+		select {
+		case s.shadows <- struct{}{}:
+			go s.shadow(ctx, method, args, call, serving, other, results, err)
+		default:
+			s.log.Debug("同时进行的影子调用达到上限, 跳过本次影子调用", "component", s.name, "method", method, "limit", cap(s.shadows))
+		}
+	}
+	return results, err
+}
+
+// shadow 在实现 other 上重复调用 method, 与 serving 的结果不一致时记录日志。
+// 影子调用不随原调用取消, 但最多进行 shadowTimeout, 结束后释放 s.shadows 中的位置。
+func (s *trafficSplit) shadow(ctx context.Context, method string, args []any, call func(context.Context, any) ([]any, error),
+	serving, other *splitTarget, want []any, wantErr error) {
+	defer func() { <-s.shadows }()
+	defer func() {
+		if e := recover(); e != nil {
+			s.log.Error("影子调用发生异常", "component", s.name, "method", method, "impl", other.impl, "e", e)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shadowTimeout)
+	defer cancel()
+	got, gotErr := call(ctx, other.obj)
+	if reflect.DeepEqual(got, want) && errorString(gotErr) == errorString(wantErr) {
+		return
+	}
+	s.log.Warn("影子调用的结果不一致",
+		"component", s.name, "method", method, "args", fmt.Sprint(args),
+		"impl", serving.impl, "result", formatResults(want, wantErr),
+		"shadow_impl", other.impl, "shadow_result", formatResults(got, gotErr),
+	)
+}
+
+// update 应用新的 traffic 配置。实现的选择在创建时确定, traffic.impl 变化时返回错误。
+func (s *trafficSplit) update(t *config.Traffic) error {
+	if t == nil {
+		// 删除 traffic 配置等同于所有调用回到主实现
+		t = &config.Traffic{Impl: s.alternate.impl}
+	}
+	if !strings.EqualFold(t.Impl, s.alternate.impl) {
+		return errors.Errorf("组件 %q 的 traffic.impl 从 %q 变为 %q, 需要重启应用才能生效", s.name, s.alternate.impl, t.Impl)
+	}
+	if err := validateTraffic(s.name, t); err != nil {
+		return err
+	}
+	s.traffic.Store(t)
+	return nil
+}
+
+func validateTraffic(name string, t *config.Traffic) error {
+	if t.Weight < 0 || t.Weight > 100 {
+		return errors.Errorf("组件 %q 的 traffic.weight 必须在 0 到 100 之间, 但值是 %v", name, t.Weight)
+	}
+	return nil
+}
+
+// newTrafficSplit 实例化 traffic.impl 指定的实现, 并返回在它和 reg 之间分配调用的 trafficSplit。
+//
+// REQUIRES: w.mu is held.
+func (w *widget) newTrafficSplit(reg *codegen.Registration, impl any, traffic *config.Traffic) (*trafficSplit, error) {
+	if err := validateTraffic(reg.Name, traffic); err != nil {
+		return nil, err
+	}
+
+	var alternate *codegen.Registration
+	for _, r := range w.regs {
+		if r.Interface == reg.Interface && implNameMatches(traffic.Impl, r) {
+			alternate = r
+		}
+	}
+	if alternate == nil {
+		return nil, errors.Errorf("组件 %q 没有名为 %q 的实现, 请检查 traffic.impl 配置", reg.Name, traffic.Impl)
+	}
+	if alternate == reg {
+		return nil, errors.Errorf("组件 %q 的 traffic.impl 与选择的实现相同", reg.Name)
+	}
+
+	obj, err := w.get(alternate)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &trafficSplit{
+		name:      reg.Name,
+		primary:   &splitTarget{reg: reg, impl: displayImplName(reg), obj: impl},
+		alternate: &splitTarget{reg: alternate, impl: displayImplName(alternate), obj: obj},
+		shadows:   make(chan struct{}, maxShadowCalls),
+		log:       w.logger("weaver"),
+	}
+	s.traffic.Store(traffic)
+	w.logger("weaver").Info("组件的调用按权重分配给两个实现",
+		"component", reg.Name, "impl", s.primary.impl, "alternate", s.alternate.impl,
+		"weight", traffic.Weight, "shadow", traffic.Shadow)
+	return s, nil
+}
+
+// trafficConfig 返回组件 reg 的 traffic 配置, 没有配置时返回 nil。
+func (w *widget) trafficConfig(reg *codegen.Registration) *config.Traffic {
+	return trafficConfig(w.option, reg)
+}
+
+func trafficConfig(option *config.Config, reg *codegen.Registration) *config.Traffic {
+	for name, c := range option.Components {
+		if c.Traffic != nil && configNameMatches(name, reg.Name) {
+			return c.Traffic
+		}
+	}
+	return nil
+}
+
+// reloadTrafficLocked 将 traffic 配置中 weight 和 shadow 的变化应用到已经创建的 trafficSplit。
+//
+// REQUIRES: w.mu is held.
+func (w *widget) reloadTrafficLocked() {
+	if len(w.splits) == 0 {
+		return
+	}
+
+	option := new(config.Config)
+	if err := w.conf.Unmarshal("weaver", option); err != nil {
+		w.logger("weaver").Error("解析 weaver 配置失败", "err", err)
+		return
+	}
+	for name, split := range w.splits {
+		t := trafficConfig(option, w.regsByName[name])
+		if err := split.update(t); err != nil {
+			w.logger("weaver").Error("更新组件的流量分配失败", "component", name, "err", err)
+			continue
+		}
+		if t != nil {
+			w.logger("weaver").Info("组件的流量分配已更新", "component", name, "weight", t.Weight, "shadow", t.Shadow)
+		}
+	}
+}
+
+// instanceName 返回组件实现 reg 的实例名。默认实现使用组件名, 命名的实现在组件名后加上 [实现名],
+// 使同一个接口的多个实现可以同时实例化。
+func instanceName(reg *codegen.Registration) string {
+	if reg.ImplName == "" {
+		return reg.Name
+	}
+	return reg.Name + "[" + reg.ImplName + "]"
+}
+
+// implNameMatches 返回配置中的实现名 name 是否指向实现 reg, default 指没有名称的默认实现。
+func implNameMatches(name string, reg *codegen.Registration) bool {
+	if reg.ImplName == "" {
+		return strings.EqualFold(name, "default")
+	}
+	return strings.EqualFold(name, reg.ImplName)
+}
+
+func displayImplName(reg *codegen.Registration) string {
+	if reg.ImplName == "" {
+		return "default"
+	}
+	return reg.ImplName
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func formatResults(results []any, err error) string {
+	if err != nil {
+		return fmt.Sprintf("%v, err: %v", results, err)
+	}
+	return fmt.Sprint(results)
+}
@@ -0,0 +1,215 @@
+package weaver
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/jun3372/weaver/internal/config"
+	"github.com/jun3372/weaver/runtime/codegen"
+)
+
+type greeterComponent interface {
+	Greet(ctx context.Context, name string) (string, error)
+}
+
+type oldGreeter struct {
+	Implements[greeterComponent]
+}
+
+func (*oldGreeter) Greet(_ context.Context, name string) (string, error) { return "hello " + name, nil }
+
+type newGreeter struct {
+	Implements[greeterComponent] `weaver:"impl=next"`
+}
+
+func (*newGreeter) Greet(_ context.Context, name string) (string, error) { return "hi " + name, nil }
+
+// greeter_local_stub 与 weaver generate 生成的本地存根相同。
+type greeter_local_stub struct {
+	invoke       codegen.Invoker
+	greetMetrics *codegen.MethodMetrics
+}
+
+func (s greeter_local_stub) Greet(ctx context.Context, a0 string) (r0 string, err error) {
+	begin := s.greetMetrics.Begin()
+	results, err := s.invoke(ctx, "Greet", []any{a0}, func(ctx context.Context, impl any) ([]any, error) {
+		r0, err := impl.(greeterComponent).Greet(ctx, a0)
+		return []any{r0}, err
+	})
+	s.greetMetrics.End(begin, err != nil)
+	if len(results) == 1 {
+		r0, _ = results[0].(string)
+	}
+	return
+}
+
+type greeterClient struct {
+	Implements[serverComponent]
+	greeter Ref[greeterComponent]
+}
+
+func greeterRegistrations() []*codegen.Registration {
+	greeterType := reflect.TypeOf((*greeterComponent)(nil)).Elem()
+	stub := func(invoke codegen.Invoker, caller string) any {
+		return greeter_local_stub{
+			invoke:       invoke,
+			greetMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "test/greeter/Greeter", Method: "Greet"}),
+		}
+	}
+	return []*codegen.Registration{
+		{Name: "test/greeter/Greeter", Interface: greeterType, Impl: reflect.TypeOf(oldGreeter{}), LocalStubFn: stub},
+		{Name: "test/greeter/Greeter", Interface: greeterType, Impl: reflect.TypeOf(newGreeter{}), ImplName: "next", LocalStubFn: stub},
+		{Name: "test/client", Interface: reflect.TypeOf((*serverComponent)(nil)).Elem(), Impl: reflect.TypeOf(greeterClient{})},
+	}
+}
+
+// syncBuffer 是可以在多个 goroutine 中写入的 bytes.Buffer。
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func newTrafficWidget(t *testing.T, traffic string) (*widget, *syncBuffer) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(strings.NewReader("weaver: {components: {greeter.Greeter: {traffic: " + traffic + "}}}\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	var logs syncBuffer
+	w := newWidget(ctx, cancel, newViperProvider(v, nil), greeterRegistrations())
+	w.log = slog.New(slog.NewTextHandler(&logs, nil))
+	return w, &logs
+}
+
+func TestTrafficSplit(t *testing.T) {
+	w, _ := newTrafficWidget(t, "{impl: next, weight: 100}")
+	obj, err := w.getImpl(reflect.TypeOf(greeterClient{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"test/greeter/Greeter", "test/greeter/Greeter[next]"}; !slices.Equal(w.deps["test/client"], want) {
+		t.Fatalf("deps[test/client] = %v, want %v", w.deps["test/client"], want)
+	}
+
+	greeter := obj.(*greeterClient).greeter.Get()
+	for _, test := range []struct {
+		weight float64
+		want   string
+	}{
+		{100, "hi weaver"},
+		{0, "hello weaver"},
+	} {
+		if err := w.splits["test/greeter/Greeter"].update(&config.Traffic{Impl: "next", Weight: test.weight}); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 10; i++ {
+			if got, err := greeter.Greet(context.Background(), "weaver"); err != nil || got != test.want {
+				t.Fatalf("weight %v: Greet() = %q, %v, want %q", test.weight, got, err, test.want)
+			}
+		}
+	}
+
+	if err := w.splits["test/greeter/Greeter"].update(&config.Traffic{Impl: "default"}); err == nil {
+		t.Fatal("expected an error changing traffic.impl")
+	}
+}
+
+func TestTrafficShadow(t *testing.T) {
+	w, logs := newTrafficWidget(t, "{impl: next, shadow: true}")
+	obj, err := w.getImpl(reflect.TypeOf(greeterClient{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := obj.(*greeterClient).greeter.Get().Greet(context.Background(), "weaver")
+	if err != nil || got != "hello weaver" {
+		t.Fatalf("Greet() = %q, %v, want %q", got, err, "hello weaver")
+	}
+
+	// 影子调用在后台进行
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(logs.String(), "影子调用的结果不一致") {
+		if time.Now().After(deadline) {
+			t.Fatalf("no mismatch logged, logs:\n%s", logs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, want := range []string{"method=Greet", "impl=default", `result="[hello weaver]"`, "shadow_impl=next", `shadow_result="[hi weaver]"`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs = %s, want them to contain %s", logs, want)
+		}
+	}
+}
+
+func TestTrafficUnknownImpl(t *testing.T) {
+	w, _ := newTrafficWidget(t, "{impl: redis, weight: 10}")
+	_, err := w.getImpl(reflect.TypeOf(greeterClient{}))
+	if want := `组件 "test/greeter/Greeter" 没有名为 "redis" 的实现`; err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("getImpl() = %v, want an error containing %q", err, want)
+	}
+}
+
+func TestTrafficShadowLimit(t *testing.T) {
+	w, logs := newTrafficWidget(t, "{impl: next, shadow: true}")
+	w.log = slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	obj, err := w.getImpl(reflect.TypeOf(greeterClient{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	greeter := obj.(*greeterClient).greeter.Get()
+
+	// 占满影子调用的位置, 新的影子调用被跳过
+	split := w.splits["test/greeter/Greeter"]
+	for i := 0; i < maxShadowCalls; i++ {
+		split.shadows <- struct{}{}
+	}
+	if _, err := greeter.Greet(context.Background(), "weaver"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logs.String(), "跳过本次影子调用") {
+		t.Fatalf("no skipped shadow call logged, logs:\n%s", logs)
+	}
+
+	// 释放一个位置后影子调用恢复
+	<-split.shadows
+	if _, err := greeter.Greet(context.Background(), "weaver"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(logs.String(), "影子调用的结果不一致") {
+		if time.Now().After(deadline) {
+			t.Fatalf("no mismatch logged, logs:\n%s", logs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if strings.Count(logs.String(), "影子调用的结果不一致") != 1 {
+		t.Fatalf("want exactly one shadow call, logs:\n%s", logs)
+	}
+}
@@ -2,7 +2,6 @@ package version
 
 import (
 	"fmt"
-	"os"
 )
 
 var (
@@ -33,5 +32,4 @@ func PrintVersion() {
 	if BuildTime != "" {
 		fmt.Printf("Build Time: %s\n", BuildTime)
 	}
-	os.Exit(0)
 }
@@ -5,26 +5,35 @@ import (
 	"flag"
 	"log/slog"
 	"os"
-	"os/signal"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 
-	"github.com/jun3372/weaver/internal/reflection"
-	"github.com/jun3372/weaver/runtime/codegen"
+	"github.com/jun3372/weaver/internal/private"
 	"github.com/jun3372/weaver/runtime/logger"
 	"github.com/jun3372/weaver/version"
 )
 
 type Main interface{}
 
-func Run[T any, P PointerToMain[T]](ctx context.Context, app func(context.Context, *T) error) error {
-	var filename string
+// Run 解析命令行参数, 创建并运行 main 组件为 T 的应用, 收到退出信号时停止。
+// opts 在命令行参数对应的选项之后应用, 例如 weaver.WithInterceptors。
+func Run[T any, P PointerToMain[T]](ctx context.Context, app func(context.Context, *T) error, opts ...AppOption) error {
+	var filenames stringsFlag
+	var profile string
 	var printVersion bool
-	flag.StringVar(&filename, "conf", os.Getenv("SERVICE_CONFIG"), "config file path")
+	if env := os.Getenv("SERVICE_CONFIG"); env != "" {
+		filenames.values = strings.Split(env, ",")
+	}
+	flag.Var(&filenames, "conf", "config file path, can be repeated to merge multiple files in order")
+	flag.StringVar(&profile, "profile", os.Getenv("SERVICE_PROFILE"), "config profile, loads weaver.<profile>.yaml next to each config file")
 	flag.BoolVar(&printVersion, "version", strings.ToLower(os.Getenv("SERVICE_VERSION")) == "true", "print version info")
 	flag.Parse()
 
@@ -33,42 +42,126 @@ func Run[T any, P PointerToMain[T]](ctx context.Context, app func(context.Contex
 		return nil
 	}
 
-	var conf *viper.Viper
-	if filename != "" {
-		conf = viper.New()
-		conf.SetConfigFile(filename)
-		if err := conf.ReadInConfig(); err != nil {
-			return errors.Errorf("Fatal error config file: %v", err)
-		}
+	options := []AppOption{WithSignals(syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)}
+	for _, filename := range filenames.values {
+		options = append(options, WithConfigFile(filename))
+	}
+	if profile != "" {
+		options = append(options, WithProfile(profile))
 	}
 
-	var cancel context.CancelFunc
-	ctx, cancel = signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
-	widget := newWidget(ctx, cancel, conf, codegen.Registered())
-	main, err := widget.getImpl(reflection.Type[T]())
+	a, err := NewApp[T, P](append(options, opts...)...)
 	if err != nil {
 		return err
 	}
+	return a.Run(ctx, app)
+}
 
-	// 启动组件
-	if err = widget.start(widget.ctx); err != nil {
-		return err
+// stringsFlag 是可以重复指定的命令行参数, 第一次在命令行中指定时会替换环境变量提供的默认值。
+type stringsFlag struct {
+	values []string
+	set    bool
+}
+
+func (f *stringsFlag) String() string { return strings.Join(f.values, ",") }
+
+func (f *stringsFlag) Set(value string) error {
+	if !f.set {
+		f.values, f.set = nil, true
+	}
+	f.values = append(f.values, value)
+	return nil
+}
+
+func init() {
+	private.Run = func(ctx context.Context, opts private.Options, main reflect.Type, app func(context.Context, any) error) error {
+		var conf *viper.Viper
+		if opts.Config != "" {
+			conf = viper.New()
+			conf.SetConfigType("yaml")
+			if err := conf.ReadConfig(strings.NewReader(opts.Config)); err != nil {
+				return errors.Errorf("Fatal error config: %v", err)
+			}
+		}
+
+		r, err := newRunner(main, WithViper(conf), withFakes(opts.Fakes))
+		if err != nil {
+			return err
+		}
+		return r.run(ctx, app)
+	}
+}
+
+// WithConfig 将配置注入到组件中, 配置键通过 weaver、config 或 conf 标签指定:
+//
+//	type impl struct {
+//		weaver.Implements[T]
+//		weaver.WithConfig[options] `conf:"user"`
+//	}
+//
+// 配置以不可变快照的形式保存, 配置变化时整体原子替换, 因此并发读取时
+// 只会看到完整的旧配置或完整的新配置。
+type WithConfig[T any] struct {
+	config atomic.Pointer[T]
+
+	mu          sync.Mutex
+	subscribers map[int]func(old, new *T)
+	nextID      int
+}
+
+// Config 返回当前配置的快照。快照在多个 goroutine 之间共享, 调用方不能修改它。
+func (c *WithConfig[T]) Config() *T {
+	if p := c.config.Load(); p != nil {
+		return p
+	}
+
+	c.config.CompareAndSwap(nil, new(T))
+	return c.config.Load()
+}
+
+// OnChange 注册一个在配置变化后调用的函数, 返回的函数用于取消注册。
+// fn 在配置已经替换为 new 之后调用。
+func (c *WithConfig[T]) OnChange(fn func(old, new *T)) (cancel func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.subscribers == nil {
+		c.subscribers = map[int]func(old, new *T){}
 	}
 
-	if m, ok := main.(*T); !ok {
-		return errors.New("main type error")
-	} else {
-		err = app(ctx, m)
+	id := c.nextID
+	c.nextID++
+	c.subscribers[id] = fn
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.subscribers, id)
 	}
+}
 
-	cancel()
-	widget.shutdown(context.Background())
-	return err
+func (c *WithConfig[T]) configType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
 }
 
-type WithConfig[T any] struct{ config T }
+func (c *WithConfig[T]) load() any {
+	return c.Config()
+}
 
-func (c *WithConfig[T]) Config() *T { return &c.config }
+func (c *WithConfig[T]) swap(v any) (old any) {
+	return c.config.Swap(v.(*T))
+}
+
+func (c *WithConfig[T]) notify(old, new any) {
+	c.mu.Lock()
+	subscribers := make([]func(old, new *T), 0, len(c.subscribers))
+	for _, fn := range c.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	c.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(old.(*T), new.(*T))
+	}
+}
 
 type Ref[T any] struct{ value T }
 
@@ -88,8 +181,10 @@ type InstanceOf[T any] interface {
 }
 type Implements[T any] struct {
 	// Component logger.
-	logger *slog.Logger
-	exec   context.CancelFunc
+	logger         *slog.Logger
+	exec           context.CancelFunc
+	healthReport   func() HealthReport
+	tracerProvider trace.TracerProvider
 
 	// weaverInfo *weaver.WeaverInfo
 
@@ -134,4 +229,30 @@ func (i *Implements[T]) Exec() {
 	i.exec()
 }
 
+func (i *Implements[T]) setHealthReport(fn func() HealthReport) {
+	i.healthReport = fn
+}
+
+func (i *Implements[T]) setTracerProvider(tp trace.TracerProvider) {
+	i.tracerProvider = tp
+}
+
+// TracerProvider 返回运行时按 weaver.tracing 配置创建的 TracerProvider, 未开启追踪时返回不记录 span 的实现。
+// 运行时不会修改全局的 TracerProvider, 组件使用 otelhttp 等库时应通过 WithTracerProvider 之类的选项传入它,
+// 这些库创建的 span 才会与组件方法调用的 span 位于同一个调用树中。
+func (i *Implements[T]) TracerProvider() trace.TracerProvider {
+	if i.tracerProvider == nil {
+		return noop.NewTracerProvider()
+	}
+	return i.tracerProvider
+}
+
+// HealthReport 返回运行时最近一次缓存的所有组件的健康检查结果。
+func (i *Implements[T]) HealthReport() HealthReport {
+	if i.healthReport == nil {
+		return HealthReport{}
+	}
+	return i.healthReport()
+}
+
 func (Implements[T]) implements(T) {}
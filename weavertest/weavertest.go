@@ -0,0 +1,75 @@
+// Package weavertest 提供在单元测试中运行 weaver 组件的工具。
+//
+//	func TestApp(t *testing.T) {
+//		weavertest.Run(t, func(ctx context.Context, app *app) error {
+//			resp, err := app.Get().SayHello(ctx, "weaver")
+//			...
+//		}, weavertest.WithConfig(`app: {appname: test}`), weavertest.WithFakes(
+//			weavertest.Fake[user.User](&fakeUser{}),
+//		))
+//	}
+//
+// 与 weaver.Run 不同, Run 不会解析命令行参数、不会安装信号处理器, 也不需要磁盘上的配置文件。
+package weavertest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/jun3372/weaver"
+	"github.com/jun3372/weaver/internal/private"
+	"github.com/jun3372/weaver/internal/reflection"
+)
+
+// FakeComponent 是用来替换某个组件的实现, 通过 Fake 创建。
+type FakeComponent struct {
+	intf reflect.Type
+	impl any
+}
+
+// Fake 返回一个用 impl 替换接口为 T 的组件的 FakeComponent。
+// 被替换的组件不会被实例化, impl 也不会被调用 Init、Start 或 Shutdown。
+func Fake[T any](impl T) FakeComponent {
+	t := reflection.Type[T]()
+	if t.Kind() != reflect.Interface {
+		panic(fmt.Errorf("Fake: %v is not an interface", t))
+	}
+	return FakeComponent{intf: t, impl: impl}
+}
+
+type Option func(*private.Options)
+
+// WithConfig 设置 YAML 格式的配置内容。
+func WithConfig(config string) Option {
+	return func(o *private.Options) {
+		o.Config = config
+	}
+}
+
+// WithFakes 使用 fakes 替换对应的组件。
+func WithFakes(fakes ...FakeComponent) Option {
+	return func(o *private.Options) {
+		for _, fake := range fakes {
+			o.Fakes[fake.intf] = fake.impl
+		}
+	}
+}
+
+// Run 实例化 main 组件及其依赖并启动它们, 然后调用 body。
+// body 返回后所有组件会被关闭; body 或组件初始化返回错误时测试失败。
+func Run[T any, _ weaver.PointerToMain[T]](t testing.TB, body func(context.Context, *T) error, opts ...Option) {
+	t.Helper()
+	options := private.Options{Fakes: map[reflect.Type]any{}}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	err := private.Run(context.Background(), options, reflection.Type[T](), func(ctx context.Context, main any) error {
+		return body(ctx, main.(*T))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
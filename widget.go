@@ -2,16 +2,20 @@ package weaver
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"log/slog"
+	"os"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"unsafe"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/pkg/errors"
-	"github.com/spf13/viper"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/jun3372/weaver/internal/config"
@@ -19,25 +23,44 @@ import (
 	"github.com/jun3372/weaver/runtime/logger"
 )
 
-var (
-	once sync.Once
-	log  *slog.Logger
+// 组件的生命周期状态
+const (
+	stateInitialized = "initialized"
+	stateStarted     = "started"
+	stateFailed      = "failed"
+	stateStopped     = "stopped"
 )
 
 type widget struct {
 	ctx             context.Context
-	conf            *viper.Viper
+	conf            ConfigProvider
 	option          *config.Config
+	logOnce         sync.Once
+	log             *slog.Logger
 	mu              sync.Mutex
 	cancel          context.CancelFunc
-	regsByName      map[string]*codegen.Registration       // registrations by component name
-	regsByInterface map[reflect.Type]*codegen.Registration // registrations by component interface type
+	regs            []*codegen.Registration                // all registrations, including implementations that are not selected
+	regsByName      map[string]*codegen.Registration       // selected registrations by component name
+	regsByInterface map[reflect.Type]*codegen.Registration // selected registrations by component interface type
 	regsByImpl      map[reflect.Type]*codegen.Registration // registrations by component implementation type
-	components      map[string]any                         // components, by name
-	watchConfig     []func()
+	implErrs        map[reflect.Type]error                 // errors selecting an implementation, by component interface type
+	splits          map[string]*trafficSplit               // traffic splits, by component name
+	components      map[string]any                         // components, by instance name
+	fakes           map[reflect.Type]any                   // fake implementations, by component interface type
+	standIns        map[reflect.Type]any                   // stand-ins for disabled components, by component interface type
+	interceptors    []Interceptor                          // interceptors for method calls through local stubs
+	tracerProvider  *sdktrace.TracerProvider               // creates spans for method calls through local stubs, nil if tracing is disabled
+	listeners       []*namedListener                       // listeners opened for weaver.Listener fields, in the order they were opened
+	deps            map[string][]string                    // component name -> names of the components it holds a Ref to
+	order           []string                               // instantiated component names, dependencies first
+	resolving       []string                               // names of the components currently being instantiated
+	states          map[string]string                      // lifecycle state, by component name
+	stops           map[string]context.CancelFunc          // cancels the context passed to Start, by component name
+	health          health                                 // cached health and readiness results
+	bindings        map[string][]*configBinding            // WithConfig fields, by component name
 }
 
-func newWidget(ctx context.Context, cancel context.CancelFunc, conf *viper.Viper, regs []*codegen.Registration) *widget {
+func newWidget(ctx context.Context, cancel context.CancelFunc, conf ConfigProvider, regs []*codegen.Registration) *widget {
 	w := widget{
 		ctx:             ctx,
 		conf:            conf,
@@ -46,30 +69,46 @@ func newWidget(ctx context.Context, cancel context.CancelFunc, conf *viper.Viper
 		regsByName:      map[string]*codegen.Registration{},
 		regsByInterface: map[reflect.Type]*codegen.Registration{},
 		regsByImpl:      map[reflect.Type]*codegen.Registration{},
+		implErrs:        map[reflect.Type]error{},
+		splits:          map[string]*trafficSplit{},
+		regs:            regs,
 		components:      make(map[string]any),
-		watchConfig:     []func(){},
+		deps:            make(map[string][]string),
+		states:          make(map[string]string),
+		stops:           make(map[string]context.CancelFunc),
+		bindings:        make(map[string][]*configBinding),
 	}
 
-	for _, reg := range regs {
-		w.regsByName[reg.Name] = reg
-		w.regsByImpl[reg.Impl] = reg
-		w.regsByInterface[reg.Interface] = reg
+	if err := config.SetDefaults(w.option); err != nil {
+		slog.Warn("failed to set system config defaults", "err", err)
 	}
 
 	if w.conf != nil {
-		if err := conf.UnmarshalKey("weaver", &w.option); err != nil {
+		if err := conf.Unmarshal("weaver", w.option); err != nil {
 			slog.Warn("failed to unmarshal system config", "err", err)
 		}
+	}
 
-		conf.WatchConfig()
-		conf.OnConfigChange(func(e fsnotify.Event) {
-			for _, fn := range w.watchConfig {
-				fn()
-			}
+	for name := range w.option.Components {
+		if w.componentByConfigName(name) == nil {
+			slog.Warn("weaver.components 中的组件不存在", "name", name)
+		}
+	}
 
-			w.shutdown(context.Background())
-			w.start(context.Background())
-		})
+	// 同一个接口可以有多个实现, 每个接口只选择其中一个实例化
+	impls := map[reflect.Type][]*codegen.Registration{}
+	for _, reg := range regs {
+		w.regsByImpl[reg.Impl] = reg
+		impls[reg.Interface] = append(impls[reg.Interface], reg)
+	}
+	for t, regs := range impls {
+		reg, err := w.selectImpl(regs)
+		if err != nil {
+			w.implErrs[t] = err
+			continue
+		}
+		w.regsByName[reg.Name] = reg
+		w.regsByInterface[t] = reg
 	}
 
 	return &w
@@ -82,11 +121,26 @@ func (w *widget) GetInterface(t reflect.Type) (any, error) {
 }
 
 func (w *widget) getInterface(t reflect.Type) (any, error) {
+	if fake, ok := w.fakes[t]; ok {
+		return fake, nil
+	}
+
+	if err, ok := w.implErrs[t]; ok {
+		return nil, err
+	}
+
 	reg, ok := w.regsByInterface[t]
 	if !ok {
 		return nil, errors.Errorf("component %v not found; maybe you forgot to run weaver generate", t)
 	}
 
+	if !w.enabled(reg) {
+		if standIn, ok := w.standIns[t]; ok {
+			return standIn, nil
+		}
+		return nil, &disabledError{name: reg.Name}
+	}
+
 	c, err := w.get(reg)
 	if err != nil {
 		return nil, err
@@ -102,12 +156,98 @@ func (w *widget) getImpl(t reflect.Type) (any, error) {
 	if !ok {
 		return nil, errors.Errorf("component implementation %v not found; maybe you forgot to run weaver generate", t)
 	}
+	if !w.enabled(reg) {
+		return nil, errors.Errorf("组件 %q 不能被禁用, 请检查 weaver.components 配置", reg.Name)
+	}
+	if w.regsByInterface[reg.Interface] != reg {
+		return nil, errors.Errorf("组件 %q 的实现 %v 没有被选中, 请检查 weaver.components 配置", reg.Name, t)
+	}
 
 	return w.get(reg)
 }
 
+// disabledError 表示引用的组件已经通过 weaver.components.<name>.enabled 禁用, 并且没有替代实现。
+type disabledError struct {
+	name string
+}
+
+func (e *disabledError) Error() string {
+	return fmt.Sprintf("组件 %q 已通过 weaver.components 配置禁用, 可以使用 weaver.WithStandIn 为它提供替代实现", e.name)
+}
+
+// selectImpl 从同一个接口的实现 regs 中选择要实例化的实现: 优先使用 weaver.components.<name>.impl
+// 指定的实现, 没有指定时使用唯一的实现或者没有名称的默认实现。
+func (w *widget) selectImpl(regs []*codegen.Registration) (*codegen.Registration, error) {
+	name := regs[0].Name
+	names := make([]string, 0, len(regs))
+	for _, reg := range regs {
+		names = append(names, strconv.Quote(reg.ImplName))
+	}
+	slices.Sort(names)
+
+	for key, c := range w.option.Components {
+		if c.Impl == "" || !configNameMatches(key, name) {
+			continue
+		}
+		for _, reg := range regs {
+			if implNameMatches(c.Impl, reg) {
+				return reg, nil
+			}
+		}
+		return nil, errors.Errorf("组件 %q 没有名为 %q 的实现, 可选的实现: %s", name, c.Impl, strings.Join(names, ", "))
+	}
+
+	if len(regs) == 1 {
+		return regs[0], nil
+	}
+	for _, reg := range regs {
+		if reg.ImplName == "" {
+			return reg, nil
+		}
+	}
+	return nil, errors.Errorf("组件 %q 有多个实现 %s, 请使用 weaver.components.%s.impl 选择其中一个", name, strings.Join(names, ", "), codegen.ShortName(name))
+}
+
+// enabled 返回组件 reg 是否启用, 参见 config.Config.Components。
+func (w *widget) enabled(reg *codegen.Registration) bool {
+	for name, c := range w.option.Components {
+		if c.Enabled != nil && !*c.Enabled && configNameMatches(name, reg.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// componentByConfigName 返回 weaver.components 中的键 name 对应的组件, name 可以是组件的全名或短名称。
+func (w *widget) componentByConfigName(name string) *codegen.Registration {
+	for _, reg := range w.regs {
+		if configNameMatches(name, reg.Name) {
+			return reg
+		}
+	}
+	return nil
+}
+
+// configNameMatches 返回 weaver.components 中的键 key 是否是组件 name 的全名或短名称, 不区分大小写。
+func configNameMatches(key, name string) bool {
+	return strings.EqualFold(key, name) || strings.EqualFold(key, codegen.ShortName(name))
+}
+
+// managed 返回 Ref 解析到的接口 t 的实现是否是由运行时管理生命周期的组件, 而不是 fake 或替代实现。
+func (w *widget) managed(t reflect.Type) bool {
+	if _, ok := w.fakes[t]; ok {
+		return false
+	}
+	reg, ok := w.regsByInterface[t]
+	return ok && w.enabled(reg)
+}
+
 func (w *widget) logger(name string, attrs ...string) *slog.Logger {
-	once.Do(func() {
+	w.logOnce.Do(func() {
+		if w.log != nil {
+			return
+		}
+
 		opts := []logger.Option{
 			logger.WithType(w.option.Logger.Type),
 			logger.WithLevelString(w.option.Logger.Level),
@@ -125,17 +265,35 @@ func (w *widget) logger(name string, attrs ...string) *slog.Logger {
 			}...)
 		}
 
-		log = logger.New(opts...).Logger(context.Background())
+		w.log = logger.New(opts...).Logger(context.Background())
 	})
 
-	return log
+	return w.log
 }
 
 func (w *widget) get(reg *codegen.Registration) (any, error) {
-	if c, ok := w.components[reg.Name]; ok {
+	// 同一个接口的多个实现可能同时实例化, 参见 localStub, 因此按实例名记录组件
+	name := instanceName(reg)
+	if c, ok := w.components[name]; ok {
 		return c, nil
 	}
 
+	// 组件只有在 Init 之后才会写入 w.components, 通过 resolving 检测循环依赖
+	if i := slices.Index(w.resolving, name); i >= 0 {
+		path := make([]string, 0, len(w.resolving)-i+1)
+		for _, name := range append(w.resolving[i:], name) {
+			path = append(path, codegen.ShortName(name))
+		}
+		return nil, errors.Errorf("component dependency cycle detected: %s", strings.Join(path, " -> "))
+	}
+
+	w.resolving = append(w.resolving, name)
+	defer func() { w.resolving = w.resolving[:len(w.resolving)-1] }()
+
+	if reg.ImplName != "" {
+		w.logger("weaver").Info("使用组件的实现", "component", reg.Name, "impl", reg.ImplName)
+	}
+
 	v := reflect.New(reg.Impl)
 	obj := v.Interface()
 
@@ -146,36 +304,94 @@ func (w *widget) get(reg *codegen.Registration) (any, error) {
 		}
 	}
 
+	// 健康检查结果
+	if i, ok := obj.(interface{ setHealthReport(func() HealthReport) }); ok {
+		i.setHealthReport(w.HealthReport)
+	}
+
+	// 追踪
+	if w.tracerProvider != nil {
+		if i, ok := obj.(interface{ setTracerProvider(trace.TracerProvider) }); ok {
+			i.setTracerProvider(w.tracerProvider)
+		}
+	}
+
 	// Set logger.
-	if err := w.setLogger(obj, w.logger(reg.Name)); err != nil {
+	if err := w.setLogger(obj, w.logger(name)); err != nil {
 		return nil, err
 	}
 
-	// WithConfig
-	if w.conf != nil {
-		w.WithConfig(v)
+	// WithConfig, 没有配置文件时使用 default 标签声明的默认值
+	bindings, err := w.WithConfig(v)
+	if err != nil {
+		return nil, errors.Errorf("component %q: %v", name, err)
+	}
+	w.bindings[name] = bindings
+
+	// weaver.Listener
+	if err := w.WithListeners(obj, name); err != nil {
+		return nil, err
 	}
 
 	// WithRef
-	if err := w.WithRef(obj, func(t reflect.Type) (any, error) { return w.getInterface(t) }); err != nil {
+	if err := w.WithRef(obj, func(t reflect.Type) (any, error) {
+		c, err := w.getInterface(t)
+		var disabled *disabledError
+		if stderrors.As(err, &disabled) {
+			return nil, errors.Errorf("组件 %q 引用了已禁用的组件 %q, 可以使用 weaver.WithStandIn 为它提供替代实现", name, disabled.name)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// fake 组件和替代实现不参与生命周期管理, 不记录依赖, 也不使用本地存根
+		if w.managed(t) {
+			ref := w.regsByInterface[t]
+			w.deps[name] = append(w.deps[name], instanceName(ref))
+			return w.localStub(reg, ref, c)
+		} else if _, fake := w.fakes[t]; !fake {
+			w.logger("weaver").Info("使用替代实现代替已禁用的组件", "component", name, "ref", w.regsByInterface[t].Name)
+		}
+		return c, nil
+	}); err != nil {
 		return nil, err
 	}
 
 	if i, ok := obj.(interface{ Init(_ context.Context) error }); ok {
 		if err := i.Init(w.ctx); err != nil {
-			return nil, errors.Errorf("component %q initialization failed: %v", reg.Name, err)
+			return nil, errors.Errorf("component %q initialization failed: %v", name, err)
 		}
 	}
 
-	w.components[reg.Name] = obj
+	// 依赖的组件在 WithRef 中先于当前组件完成初始化, 因此 order 即为依赖图的拓扑序
+	w.components[name] = obj
+	w.order = append(w.order, name)
+	w.states[name] = stateInitialized
 	return obj, nil
 }
 
-func (w *widget) WithConfig(v reflect.Value) {
+// configBinding 将组件的一个 WithConfig 字段与其配置键关联起来
+type configBinding struct {
+	key     string       // 配置键
+	holder  configHolder // 组件中的 WithConfig 字段
+	raw     any          // 最近一次应用的原始配置, 用于判断配置是否变化
+	secrets []string     // raw 中由 ${env:...} 等引用解析出的值, 管理接口展示配置时会隐藏它们
+}
+
+// configHolder 由 *WithConfig[T] 实现, 配置值均为 *T 类型的快照
+type configHolder interface {
+	configType() reflect.Type
+	load() any
+	swap(v any) (old any)
+	notify(old, new any)
+}
+
+func (w *widget) WithConfig(v reflect.Value) ([]*configBinding, error) {
 	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
 		panic(errors.Errorf("invalid non pointer to struct value: %v", v))
 	}
 
+	var bindings []*configBinding
 	s := v.Elem()
 	t := s.Type()
 	for i := 0; i < t.NumField(); i++ {
@@ -196,54 +412,68 @@ func (w *widget) WithConfig(v reflect.Value) {
 			continue
 		}
 
-		if f.Anonymous {
-			configGetter := s.Field(i).Addr().MethodByName("Config").Call(nil)[0]
-			if err := w.conf.UnmarshalKey(key, configGetter.Interface()); err != nil {
-				w.logger("weaver").Error("解析配置失败", "key", key, "err", err)
-				continue
-			}
-
-			w.WatchConfig(key, func() {
-				if err := w.conf.UnmarshalKey(key, configGetter.Interface()); err != nil {
-					w.logger("weaver").Error("解析配置失败", "key", key, "err", err)
-				}
-			})
+		// 使用反射访问未导出字段
+		field := s.Field(i)
+		holder, ok := reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Interface().(configHolder)
+		if !ok {
+			w.logger("weaver").Warn("未找到 Config 字段", slog.String("key", key), slog.Any("field", field))
 			continue
 		}
 
-		// f.Anonymous == false 使用 w.conf.UnmarshalKey 方法给这个f设置值
-		field := s.FieldByIndex(f.Index)
-		if !field.CanAddr() {
-			continue
+		raw, envs, secrets, err := w.lookupConfig(key, holder.configType())
+		if err != nil {
+			return nil, err
 		}
-
-		fieldValue := reflect.New(field.Type()).Elem()
-		configField := fieldValue.Addr().MethodByName("Config")
-		if !configField.IsValid() {
-			w.logger("weaver").Warn("未找到 Config 字段", slog.String("key", key), slog.Any("field", field))
-			continue
+		if len(envs) > 0 {
+			w.logger("weaver").Info("使用环境变量覆盖配置", "struct", t, "key", key, "env", envs)
 		}
 
-		cfg := configField.Call(nil)[0].Interface()
-		if err := w.conf.UnmarshalKey(key, cfg); err != nil {
-			w.logger("weaver").Warn("解析配置信息出错", slog.String("key", key), slog.Any("configField", cfg), slog.Any("err", err))
-			continue
+		b := &configBinding{key: key, holder: holder, raw: raw, secrets: secrets}
+		cfg := reflect.New(holder.configType())
+		if err := w.decodeConfig(key, raw, cfg.Interface()); err != nil {
+			return nil, err
 		}
 
-		// 使用反射设置未导出字段的值
-		reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Set(fieldValue)
+		holder.swap(cfg.Interface())
+		bindings = append(bindings, b)
+	}
+	return bindings, nil
+}
 
-		// 监听配置变化
-		w.WatchConfig(key, func() {
-			if err := w.conf.UnmarshalKey(key, cfg); err != nil {
-				w.logger("weaver").Warn("解析配置信息出错", slog.String("key", key), slog.Any("configField", cfg), slog.Any("err", err))
-				return
-			}
+// lookupConfig 返回配置键 key 对应的原始配置: 配置文件中的值, 再叠加上覆盖它的环境变量,
+// 最后将其中的 ${env:...}、${file:...} 等引用替换为解析结果。t 是配置的类型, envs 是生效的环境变量名,
+// secrets 是引用解析出的值。
+func (w *widget) lookupConfig(key string, t reflect.Type) (raw any, envs, secrets []string, err error) {
+	if w.conf != nil {
+		raw = w.conf.Get(key)
+	}
 
-			// 使用反射设置未导出字段的值
-			reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Set(fieldValue)
-		})
+	for _, o := range w.option.Env.EnvOverrides(key, t, os.LookupEnv) {
+		raw = config.Overlay(raw, o.Path, o.Value)
+		envs = append(envs, o.Name)
 	}
+
+	if raw, secrets, err = config.ResolveSecrets(w.ctx, raw); err != nil {
+		return nil, nil, nil, errors.Errorf("解析配置 %q 中的引用失败: %v", key, err)
+	}
+	return raw, envs, secrets, nil
+}
+
+// decodeConfig 先为 cfg 设置 default 标签声明的默认值, 再将原始配置 raw 解析到 cfg 中并校验,
+// 参见 config.SetDefaults 和 config.Validate。
+func (w *widget) decodeConfig(key string, raw any, cfg any) error {
+	if err := config.SetDefaults(cfg); err != nil {
+		return errors.Errorf("配置 %q 的默认值无效: %v", key, err)
+	}
+
+	if err := config.Decode(raw, cfg); err != nil {
+		return errors.Errorf("解析配置 %q 失败: %v", key, err)
+	}
+
+	if err := config.Validate(cfg); err != nil {
+		return errors.Errorf("配置 %q 校验失败: %v", key, err)
+	}
+	return nil
 }
 
 func (w *widget) WithRef(impl any, get func(t reflect.Type) (any, error)) error {
@@ -284,17 +514,6 @@ func (w *widget) WithRef(impl any, get func(t reflect.Type) (any, error)) error
 	return nil
 }
 
-func (w *widget) WatchConfig(key string, fn func()) {
-	// 初始化切片
-	if w.watchConfig == nil {
-		// w.mu.Lock()
-		// defer w.mu.Unlock()
-		w.watchConfig = make([]func(), 0)
-	}
-
-	w.watchConfig = append(w.watchConfig, fn)
-}
-
 func (w *widget) setLogger(v any, logger *slog.Logger) error {
 	x, ok := v.(interface{ setLogger(_ *slog.Logger) })
 	if !ok {
@@ -306,45 +525,101 @@ func (w *widget) setLogger(v any, logger *slog.Logger) error {
 }
 
 func (w *widget) start(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	var wg *errgroup.Group
 	wg, ctx = errgroup.WithContext(ctx)
-	for _, impl := range w.components {
-		if i, ok := impl.(interface{ Start(_ context.Context) error }); ok {
-			go wg.Go(func() error {
-				var err error
-				defer func() {
-					if e := recover(); e != nil {
-						log.Error("Component startup encountered an exception", "err", err, "e", e)
-						w.cancel()
-						if err == nil {
-							err = e.(error)
-						}
 
-						return
-					}
-				}()
+	// 按依赖顺序为每个组件启动一个运行 Start 的 goroutine。所有组件都已完成 Init,
+	// 但 Start 之间并发执行, 不等待被依赖组件的 Start 返回
+	for _, name := range w.order {
+		w.startLocked(ctx, wg.Go, name)
+	}
+
+	// Start 通常会阻塞到 ctx 结束, 因此这里不等待 wg.Wait
+	return nil
+}
+
+// startLocked 使用 spawn 在新的 goroutine 中运行组件的 Start 方法。
+// 每个组件使用独立的 context, 在组件被关闭时取消。
+//
+// REQUIRES: w.mu is held.
+func (w *widget) startLocked(ctx context.Context, spawn func(func() error), name string) {
+	w.states[name] = stateStarted
+	i, ok := w.components[name].(interface{ Start(_ context.Context) error })
+	if !ok {
+		return
+	}
 
-				if err = i.Start(ctx); err != nil {
-					log.Error("Component startup failed", "err", err)
-					w.cancel()
-				}
+	ctx, w.stops[name] = context.WithCancel(ctx)
+	spawn(func() error { return w.runStart(ctx, name, i) })
+}
 
-				return err
-			})
+// runStart 调用组件的 Start 方法, 失败或发生 panic 时取消整个应用。
+func (w *widget) runStart(ctx context.Context, name string, i interface{ Start(_ context.Context) error }) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			w.logger("weaver").Error("Component startup encountered an exception", "component", name, "e", e)
+			w.setState(name, stateFailed)
+			w.cancel()
+			err = errors.Errorf("component %q panicked during startup: %v", name, e)
 		}
+	}()
+
+	// ctx 已经取消说明组件是被主动关闭的, 此时 Start 返回的错误不视为失败
+	if err = i.Start(ctx); err != nil && ctx.Err() == nil {
+		w.logger("weaver").Error("Component startup failed", "component", name, "err", err)
+		w.setState(name, stateFailed)
+		w.cancel()
 	}
 
-	return wg.Wait()
+	return err
 }
 
-func (w *widget) shutdown(ctx context.Context) {
+func (w *widget) shutdown(ctx context.Context) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	for c, impl := range w.components {
-		if i, ok := impl.(interface{ Shutdown(_ context.Context) error }); ok {
+
+	// 按启动的逆序关闭组件: 依赖方先于被依赖方关闭
+	var errs []error
+	for i := len(w.order) - 1; i >= 0; i-- {
+		c := w.order[i]
+		w.stopLocked(c)
+		if i, ok := w.components[c].(interface{ Shutdown(_ context.Context) error }); ok {
 			if err := i.Shutdown(ctx); err != nil {
-				fmt.Printf("Component %s failed to shutdown: %v\n", c, err)
+				w.states[c] = stateFailed
+				w.logger("weaver").Error("Component failed to shutdown", "component", c, "err", err)
+				errs = append(errs, errors.Errorf("component %q failed to shutdown: %v", c, err))
 			}
 		}
 	}
+
+	// 组件关闭之后再关闭监听器, 组件的 Shutdown 可以先优雅地停止使用监听器的服务
+	if err := w.closeListeners(); err != nil {
+		errs = append(errs, err)
+	}
+
+	// 所有组件关闭之后再导出剩余的 span
+	if err := w.stopTracing(ctx); err != nil {
+		errs = append(errs, errors.Errorf("failed to shutdown tracing: %v", err))
+	}
+	return stderrors.Join(errs...)
+}
+
+// stopLocked 取消组件 Start 使用的 context 并将组件标记为已停止。
+//
+// REQUIRES: w.mu is held.
+func (w *widget) stopLocked(name string) {
+	if stop, ok := w.stops[name]; ok {
+		stop()
+		delete(w.stops, name)
+	}
+	w.states[name] = stateStopped
+}
+
+func (w *widget) setState(name, state string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.states[name] = state
 }
@@ -0,0 +1,471 @@
+package weaver
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/jun3372/weaver/runtime/codegen"
+)
+
+var (
+	eventsMu sync.Mutex
+	events   []string
+)
+
+func record(event string) {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	events = append(events, event)
+}
+
+type storeComponent interface{}
+type cacheComponent interface{}
+type serverComponent interface{}
+
+type store struct {
+	Implements[storeComponent]
+}
+
+func (*store) Init(context.Context) error     { record("init store"); return nil }
+func (*store) Shutdown(context.Context) error { record("shutdown store"); return nil }
+
+type cache struct {
+	Implements[cacheComponent]
+	store Ref[storeComponent]
+}
+
+func (*cache) Init(context.Context) error     { record("init cache"); return nil }
+func (*cache) Shutdown(context.Context) error { record("shutdown cache"); return nil }
+
+type server struct {
+	Implements[serverComponent]
+	cache Ref[cacheComponent]
+	store Ref[storeComponent]
+}
+
+func (*server) Init(context.Context) error     { record("init server"); return nil }
+func (*server) Shutdown(context.Context) error { record("shutdown server"); return nil }
+
+func testRegistrations() []*codegen.Registration {
+	return []*codegen.Registration{
+		{Name: "test/store", Interface: reflect.TypeOf((*storeComponent)(nil)).Elem(), Impl: reflect.TypeOf(store{})},
+		{Name: "test/cache", Interface: reflect.TypeOf((*cacheComponent)(nil)).Elem(), Impl: reflect.TypeOf(cache{})},
+		{Name: "test/server", Interface: reflect.TypeOf((*serverComponent)(nil)).Elem(), Impl: reflect.TypeOf(server{})},
+	}
+}
+
+func TestDependencyOrder(t *testing.T) {
+	events = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := newWidget(ctx, cancel, nil, testRegistrations())
+	if _, err := w.getImpl(reflect.TypeOf(server{})); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"test/store", "test/cache", "test/server"}; !slices.Equal(w.order, want) {
+		t.Fatalf("order = %v, want %v", w.order, want)
+	}
+
+	if want := []string{"test/cache", "test/store"}; !slices.Equal(w.deps["test/server"], want) {
+		t.Fatalf("deps[test/server] = %v, want %v", w.deps["test/server"], want)
+	}
+
+	if err := w.start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	w.shutdown(context.Background())
+
+	want := []string{
+		"init store", "init cache", "init server",
+		"shutdown server", "shutdown cache", "shutdown store",
+	}
+	if !slices.Equal(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+}
+
+type starterStore struct {
+	Implements[storeComponent]
+	started chan string
+}
+
+func (*starterStore) Init(context.Context) error { record("init store"); return nil }
+
+// Start 一直阻塞到组件被关闭, 与通常的服务组件一样
+func (s *starterStore) Start(ctx context.Context) error {
+	record("start store")
+	s.started <- "store"
+	<-ctx.Done()
+	return nil
+}
+
+type starterServer struct {
+	Implements[serverComponent]
+	store Ref[storeComponent]
+}
+
+func (*starterServer) Init(context.Context) error { record("init server"); return nil }
+
+func (s *starterServer) Start(context.Context) error {
+	record("start server")
+	s.store.Get().(*starterStore).started <- "server"
+	return nil
+}
+
+func TestStartAfterInit(t *testing.T) {
+	events = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := newWidget(ctx, cancel, nil, []*codegen.Registration{
+		{Name: "test/store", Interface: reflect.TypeOf((*storeComponent)(nil)).Elem(), Impl: reflect.TypeOf(starterStore{})},
+		{Name: "test/server", Interface: reflect.TypeOf((*serverComponent)(nil)).Elem(), Impl: reflect.TypeOf(starterServer{})},
+	})
+	obj, err := w.getImpl(reflect.TypeOf(starterServer{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan string, 2)
+	obj.(*starterServer).store.Get().(*starterStore).started = started
+
+	if err := w.start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// store 的 Start 一直阻塞, server 的 Start 仍然会被调用
+	for range 2 {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for Start")
+		}
+	}
+	w.shutdown(context.Background())
+
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	if want := []string{"init store", "init server"}; !slices.Equal(events[:2], want) {
+		t.Fatalf("events = %v, want every Init before any Start", events)
+	}
+	if starts := events[2:]; len(starts) != 2 || !slices.Contains(starts, "start store") || !slices.Contains(starts, "start server") {
+		t.Fatalf("events = %v, want both components started", events)
+	}
+}
+
+type pingComponent interface{}
+type pongComponent interface{}
+
+type ping struct {
+	Implements[pingComponent]
+	pong Ref[pongComponent]
+}
+
+type pong struct {
+	Implements[pongComponent]
+	ping Ref[pingComponent]
+}
+
+func TestDependencyCycle(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := newWidget(ctx, cancel, nil, []*codegen.Registration{
+		{Name: "test/ping/Ping", Interface: reflect.TypeOf((*pingComponent)(nil)).Elem(), Impl: reflect.TypeOf(ping{})},
+		{Name: "test/pong/Pong", Interface: reflect.TypeOf((*pongComponent)(nil)).Elem(), Impl: reflect.TypeOf(pong{})},
+	})
+
+	_, err := w.getImpl(reflect.TypeOf(ping{}))
+	if err == nil {
+		t.Fatal("expected a dependency cycle error")
+	}
+
+	if want := "ping.Ping -> pong.Pong -> ping.Ping"; !strings.Contains(err.Error(), want) {
+		t.Fatalf("err = %v, want it to contain %q", err, want)
+	}
+}
+
+type dsnComponent interface{}
+
+type dsnConfig struct {
+	Source string `validate:"required"`
+}
+
+type dsn struct {
+	Implements[dsnComponent]
+	WithConfig[dsnConfig] `conf:"dsn"`
+}
+
+func TestInvalidConfig(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conf := viper.New()
+	conf.SetConfigType("yaml")
+	if err := conf.ReadConfig(strings.NewReader("dsn: {source: ''}\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	w := newWidget(ctx, cancel, newViperProvider(conf, nil), []*codegen.Registration{
+		{Name: "test/dsn", Interface: reflect.TypeOf((*dsnComponent)(nil)).Elem(), Impl: reflect.TypeOf(dsn{})},
+	})
+	_, err := w.getImpl(reflect.TypeOf(dsn{}))
+	if err == nil {
+		t.Fatal("expected an invalid config error")
+	}
+
+	for _, want := range []string{"test/dsn", "Source: is required"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("err = %v, want it to contain %q", err, want)
+		}
+	}
+}
+
+type httpComponent interface{}
+
+type httpConfig struct {
+	Addr    string        `default:":8080"`
+	Timeout time.Duration `default:"3s"`
+	Hosts   []string      `default:"a,b,c"`
+}
+
+type httpServer struct {
+	Implements[httpComponent]
+	WithConfig[httpConfig] `conf:"http"`
+}
+
+func TestConfigDefaults(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		config string // 为空表示没有配置文件
+		want   httpConfig
+	}{
+		{
+			name: "no config file",
+			want: httpConfig{Addr: ":8080", Timeout: 3 * time.Second, Hosts: []string{"a", "b", "c"}},
+		},
+		{
+			name:   "partial config",
+			config: "http: {hosts: [x]}\n",
+			want:   httpConfig{Addr: ":8080", Timeout: 3 * time.Second, Hosts: []string{"x"}},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var conf ConfigProvider
+			if test.config != "" {
+				v := viper.New()
+				v.SetConfigType("yaml")
+				if err := v.ReadConfig(strings.NewReader(test.config)); err != nil {
+					t.Fatal(err)
+				}
+				conf = newViperProvider(v, nil)
+			}
+
+			w := newWidget(ctx, cancel, conf, []*codegen.Registration{
+				{Name: "test/http", Interface: reflect.TypeOf((*httpComponent)(nil)).Elem(), Impl: reflect.TypeOf(httpServer{})},
+			})
+			obj, err := w.getImpl(reflect.TypeOf(httpServer{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := *obj.(*httpServer).Config(); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("Config() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("WEAVER_HTTP_ADDR", ":9090")
+	t.Setenv("WEAVER_HTTP_HOSTS", "x,y")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := newWidget(ctx, cancel, nil, []*codegen.Registration{
+		{Name: "test/http", Interface: reflect.TypeOf((*httpComponent)(nil)).Elem(), Impl: reflect.TypeOf(httpServer{})},
+	})
+	obj, err := w.getImpl(reflect.TypeOf(httpServer{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := httpConfig{Addr: ":9090", Timeout: 3 * time.Second, Hosts: []string{"x", "y"}}
+	if got := *obj.(*httpServer).Config(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Config() = %+v, want %+v", got, want)
+	}
+}
+
+func TestConfigSecrets(t *testing.T) {
+	t.Setenv("TEST_HTTP_PORT", "9090")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conf := viper.New()
+	conf.SetConfigType("yaml")
+	if err := conf.ReadConfig(strings.NewReader("http: {addr: ':${env:TEST_HTTP_PORT}', hosts: ['${env:TEST_HTTP_HOST:-x}']}\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	w := newWidget(ctx, cancel, newViperProvider(conf, nil), []*codegen.Registration{
+		{Name: "test/http", Interface: reflect.TypeOf((*httpComponent)(nil)).Elem(), Impl: reflect.TypeOf(httpServer{})},
+	})
+	obj, err := w.getImpl(reflect.TypeOf(httpServer{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := httpConfig{Addr: ":9090", Timeout: 3 * time.Second, Hosts: []string{"x"}}
+	if got := *obj.(*httpServer).Config(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Config() = %+v, want %+v", got, want)
+	}
+}
+
+type standInStore struct{}
+
+func TestDisabledComponent(t *testing.T) {
+	conf := viper.New()
+	conf.SetConfigType("yaml")
+	if err := conf.ReadConfig(strings.NewReader("weaver:\n  components:\n    test/store: {enabled: false}\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("no stand-in", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		w := newWidget(ctx, cancel, newViperProvider(conf, nil), testRegistrations())
+		_, err := w.getImpl(reflect.TypeOf(server{}))
+		if err == nil || !strings.Contains(err.Error(), `组件 "test/cache" 引用了已禁用的组件 "test/store"`) {
+			t.Fatalf("getImpl() = %v, want an error about the disabled component", err)
+		}
+	})
+
+	t.Run("stand-in", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		w := newWidget(ctx, cancel, newViperProvider(conf, nil), testRegistrations())
+		w.standIns = map[reflect.Type]any{reflect.TypeOf((*storeComponent)(nil)).Elem(): standInStore{}}
+		obj, err := w.getImpl(reflect.TypeOf(server{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := []string{"test/cache", "test/server"}; !slices.Equal(w.order, want) {
+			t.Fatalf("order = %v, want %v", w.order, want)
+		}
+		if want := []string{"test/cache"}; !slices.Equal(w.deps["test/server"], want) {
+			t.Fatalf("deps[test/server] = %v, want %v", w.deps["test/server"], want)
+		}
+		if got := obj.(*server).store.Get(); got != (standInStore{}) {
+			t.Fatalf("server.store = %v, want the stand-in", got)
+		}
+	})
+}
+
+type userComponent interface{}
+
+type memoryUser struct {
+	Implements[userComponent]
+}
+
+type postgresUser struct {
+	Implements[userComponent] `weaver:"impl=postgres"`
+}
+
+type mysqlUser struct {
+	Implements[userComponent] `weaver:"impl=mysql"`
+}
+
+type userServer struct {
+	Implements[serverComponent]
+	user Ref[userComponent]
+}
+
+func TestSelectImplementation(t *testing.T) {
+	userType := reflect.TypeOf((*userComponent)(nil)).Elem()
+	server := &codegen.Registration{Name: "test/server", Interface: reflect.TypeOf((*serverComponent)(nil)).Elem(), Impl: reflect.TypeOf(userServer{})}
+	memory := &codegen.Registration{Name: "test/user/User", Interface: userType, Impl: reflect.TypeOf(memoryUser{})}
+	postgres := &codegen.Registration{Name: "test/user/User", Interface: userType, Impl: reflect.TypeOf(postgresUser{}), ImplName: "postgres"}
+	mysql := &codegen.Registration{Name: "test/user/User", Interface: userType, Impl: reflect.TypeOf(mysqlUser{}), ImplName: "mysql"}
+
+	for _, test := range []struct {
+		name   string
+		config string
+		regs   []*codegen.Registration
+		want   any    // 选中的实现
+		err    string // 期望的错误
+	}{
+		{
+			name: "default",
+			regs: []*codegen.Registration{memory, postgres, mysql},
+			want: &memoryUser{},
+		},
+		{
+			name: "single named",
+			regs: []*codegen.Registration{postgres},
+			want: &postgresUser{},
+		},
+		{
+			name:   "configured",
+			config: "weaver: {components: {user.User: {impl: Postgres}}}\n",
+			regs:   []*codegen.Registration{memory, postgres, mysql},
+			want:   &postgresUser{},
+		},
+		{
+			name:   "unknown",
+			config: "weaver: {components: {test/user/User: {impl: redis}}}\n",
+			regs:   []*codegen.Registration{memory, postgres},
+			err:    `组件 "test/user/User" 没有名为 "redis" 的实现, 可选的实现: "", "postgres"`,
+		},
+		{
+			name: "ambiguous",
+			regs: []*codegen.Registration{postgres, mysql},
+			err:  `组件 "test/user/User" 有多个实现 "mysql", "postgres", 请使用 weaver.components.user.User.impl 选择其中一个`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var conf ConfigProvider
+			if test.config != "" {
+				v := viper.New()
+				v.SetConfigType("yaml")
+				if err := v.ReadConfig(strings.NewReader(test.config)); err != nil {
+					t.Fatal(err)
+				}
+				conf = newViperProvider(v, nil)
+			}
+
+			w := newWidget(ctx, cancel, conf, append(test.regs, server))
+			obj, err := w.getImpl(reflect.TypeOf(userServer{}))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("getImpl() = %v, want an error containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := obj.(*userServer).user.Get(); reflect.TypeOf(got) != reflect.TypeOf(test.want) {
+				t.Fatalf("userServer.user = %T, want %T", got, test.want)
+			}
+		})
+	}
+}